@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const defaultAdminCSP = "default-src 'self'; frame-ancestors 'none'"
+
+// adminRoutes are the admin paths, relative to the base path. All of them are
+// exposed unless -admin-routes says otherwise.
+var adminRoutes = []string{"/admin", "/admin/config", "/admin/password", "/admin/read-only", "/admin/projects/issue-usage", "/admin/projects/validate", "/admin/reindex", "/admin/projects", "/admin/auth/check", "/admin/flush", "/admin/import", "/admin/projects/{id}/raw"}
+
+type adminPortal struct {
+	cfg        config
+	basePath   string
+	users      map[string]*adminUser
+	passwordMu sync.Mutex
+	realm      string
+	csp        string
+
+	attributedMu   sync.Mutex
+	attributed     map[[sha256.Size]byte]*credential
+	attributedSalt string
+}
+
+func newAdminPortal(cfg config) *adminPortal {
+	users, err := parseAdminUsers(os.Getenv("ADMIN_USERS"))
+	if err != nil {
+		panic(err)
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" && len(users) == 0 {
+		panic("Required env var ADMIN PASSWORD")
+	}
+
+	realm := os.Getenv("AUTH_REALM")
+	if realm == "" {
+		realm = "admin"
+	}
+
+	csp := os.Getenv("ADMIN_CSP")
+	if csp == "" {
+		csp = defaultAdminCSP
+	}
+
+	a := &adminPortal{
+		cfg:            cfg,
+		basePath:       cfg.BasePath,
+		users:          users,
+		realm:          realm,
+		csp:            csp,
+		attributed:     map[[sha256.Size]byte]*credential{},
+		attributedSalt: rand.Text(),
+	}
+
+	if password != "" {
+		cred, err := loadCredential(a.credentialFile())
+		if err != nil {
+			panic(err)
+		}
+		if cred != nil {
+			log.Printf("WARNING: the admin password was rotated and saved in %s, which overrides ADMIN_PASSWORD; delete the file to use ADMIN_PASSWORD again", a.credentialFile())
+		}
+		if cred == nil {
+			if cred, err = newCredential(password); err != nil {
+				panic(err)
+			}
+		}
+
+		admin := &adminUser{role: roleWrite}
+		admin.credential.Store(cred)
+		a.users["admin"] = admin
+	}
+
+	return a
+}
+
+func (a *adminPortal) authenticate(r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	u := a.users[user]
+	if u == nil {
+		unknownUser.matches(pass)
+		return "", false
+	}
+	if !u.credential.Load().matches(pass) {
+		return "", false
+	}
+
+	return user, true
+}
+
+// attributionChecks lets one uncached password check for a public write run at
+// a time, so a stream of made-up passwords can hold at most one core.
+var attributionChecks = make(chan struct{}, 1)
+
+// attribute returns the user a public write is made by, or "" if it was sent
+// without valid credentials. Those routes don't need credentials, so unlike
+// authenticate it never hashes for an unknown user, and it remembers each
+// password it verified for as long as that user's credential stays the same.
+func (a *adminPortal) attribute(r *http.Request) string {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return ""
+	}
+	u := a.users[user]
+	if u == nil {
+		return ""
+	}
+
+	cred := u.credential.Load()
+	key := sha256.Sum256([]byte(a.attributedSalt + user + ":" + pass))
+	a.attributedMu.Lock()
+	verified := a.attributed[key] == cred
+	a.attributedMu.Unlock()
+	if verified {
+		return user
+	}
+
+	attributionChecks <- struct{}{}
+	matched := cred.matches(pass)
+	<-attributionChecks
+	if !matched {
+		return ""
+	}
+
+	a.attributedMu.Lock()
+	a.attributed[key] = cred
+	a.attributedMu.Unlock()
+	return user
+}
+
+func (a *adminPortal) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", a.realm))
+	writeError(w, http.StatusUnauthorized, "unauthorized")
+}
+
+// routeEnabled reports whether -admin-routes exposes method on path, either
+// through a "/path" entry or a "METHOD /path" one.
+func (a *adminPortal) routeEnabled(method, path string) bool {
+	for _, entry := range a.cfg.AdminRoutes {
+		if entry == path || entry == method+" "+path {
+			return true
+		}
+	}
+	return false
+}
+
+type adminUserKey struct{}
+
+// protect wraps an admin route with basic auth, role checks and CSRF
+// protection. Admin responses are never stored by caches. Routes disabled by
+// -admin-routes answer 404 before authentication, so they look like they
+// don't exist, and with -admin-require-https plaintext requests are refused
+// before any credentials are looked at. The route reads the user with
+// adminUserFrom rather than checking the password a second time.
+func (a *adminPortal) protect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := a.admit(w, r)
+		if !ok {
+			return
+		}
+
+		if role := requiredRole(r); role == roleWrite && a.users[user].role != roleWrite {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("%s needs the %s role", r.Method, role))
+			return
+		}
+
+		if !checkCSRF(w, r, a.basePath+"/admin") {
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		next(w, r.WithContext(context.WithValue(r.Context(), adminUserKey{}, user)))
+	}
+}
+
+// adminUserFrom returns the user protect authenticated r as.
+func adminUserFrom(r *http.Request) string {
+	user, _ := r.Context().Value(adminUserKey{}).(string)
+	return user
+}
+
+// admit runs the checks every admin request goes through before its role is
+// considered: the route must be enabled, served over HTTPS if required, and
+// sent with valid credentials. It answers the request itself when one fails.
+func (a *adminPortal) admit(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !a.routeEnabled(r.Method, adminRoutePath(r)) {
+		http.NotFound(w, r)
+		return "", false
+	}
+
+	if a.cfg.AdminRequireHTTPS && !requestIsHTTPS(r) {
+		writeError(w, http.StatusForbidden, "admin requests must use HTTPS")
+		return "", false
+	}
+
+	user, ok := a.authenticate(r)
+	if !ok {
+		a.unauthorized(w)
+		return "", false
+	}
+	return user, true
+}
+
+// adminRoutePath names the admin route r was sent to. Routes are named by the
+// pattern they were registered with, so one with a wildcard like {id} is a
+// single entry in -admin-routes.
+func adminRoutePath(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return r.URL.Path
+}
+
+// checkAuth lets automation verify its credentials without loading the
+// dashboard. It changes nothing, so it needs neither the write role nor a
+// CSRF token. Like every admin route, it has no lockout or rate limit on
+// failed attempts.
+func (a *adminPortal) checkAuth(w http.ResponseWriter, r *http.Request) {
+	// The method is checked first so a GET is told to use POST whatever its
+	// credentials, unless the route is turned off altogether.
+	if r.Method != "POST" {
+		if !a.routeEnabled("POST", adminRoutePath(r)) {
+			http.NotFound(w, r)
+			return
+		}
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+
+	user, ok := a.admit(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	writeJSON(w, r, http.StatusOK, map[string]any{"ok": true, "user": user, "role": a.users[user].role})
+}
+
+// dashboardTemplate goes through html/template so anything dynamic it shows,
+// like the username, is escaped.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(
+	`<html><h1> Welcome to the admin dashboard </h1><p>Signed in as {{.User}}</p></html>`))
+
+type dashboardData struct {
+	User string
+}
+
+func (a *adminPortal) handler(w http.ResponseWriter, r *http.Request) {
+	user := adminUserFrom(r)
+
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, dashboardData{User: user}); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", a.csp)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
+
+type adminConfig struct {
+	Server config `json:"server"`
+	Realm  string `json:"auth_realm"`
+	CSP    string `json:"admin_csp"`
+}
+
+// config reports the effective runtime configuration. Credentials are never
+// part of it. Read-only mode can be switched at runtime, so it is reported as
+// it is now rather than as it was at startup.
+func (a *adminPortal) config(w http.ResponseWriter, r *http.Request) {
+	cfg := a.cfg
+	cfg.ReadOnly = readOnly.Load()
+	writeJSON(w, r, http.StatusOK, adminConfig{
+		Server: cfg,
+		Realm:  a.realm,
+		CSP:    a.csp,
+	})
+}
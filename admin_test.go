@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminUnauthorized(t *testing.T) {
+	_, mux := newTestAdmin(t)
+
+	rec := serve(mux, httptest.NewRequest("GET", "/admin", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, want 401", rec.Code)
+	}
+	if got, want := rec.Header().Get("WWW-Authenticate"), `Basic realm="admin"`; got != want {
+		t.Errorf("got WWW-Authenticate %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	if got, want := rec.Body.String(), `{"error":"unauthorized"}`; got != want {
+		t.Errorf("got body %s, want %s", got, want)
+	}
+}
+
+func TestDashboardEscapesUsername(t *testing.T) {
+	admin, mux := newTestAdmin(t)
+	const name = `<script>alert("hi")</script>`
+	cred, err := newCredential(testAdminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &adminUser{role: roleRead}
+	user.credential.Store(cred)
+	admin.users[name] = user
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.SetBasicAuth(name, testAdminPassword)
+	rec := serve(mux, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "<script>") {
+		t.Errorf("username was not escaped: %s", body)
+	}
+	if want := `&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;`; !strings.Contains(body, want) {
+		t.Errorf("body %s doesn't contain the escaped username %s", body, want)
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	admin, _ := newTestAdmin(t)
+	attribute := func(user, password string) string {
+		req := httptest.NewRequest("POST", "/opensource/projects", nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		return admin.attribute(req)
+	}
+
+	for _, tt := range []struct{ name, user, password, want string }{
+		{name: "no credentials"},
+		{name: "unknown user", user: "mallory", password: testAdminPassword},
+		{name: "wrong password", user: "admin", password: "wrong-password-1"},
+		{name: "valid", user: "admin", password: testAdminPassword, want: "admin"},
+		{name: "valid again, from the cache", user: "admin", password: testAdminPassword, want: "admin"},
+	} {
+		if got := attribute(tt.user, tt.password); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if len(admin.attributed) != 1 {
+		t.Errorf("got %d cached credentials, want only the valid one", len(admin.attributed))
+	}
+
+	rotated, err := newCredential("rotated-password-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.users["admin"].credential.Store(rotated)
+	if got := attribute("admin", testAdminPassword); got != "" {
+		t.Errorf("old password after rotation: got %q, want it rejected", got)
+	}
+	if got := attribute("admin", "rotated-password-1"); got != "admin" {
+		t.Errorf("new password after rotation: got %q, want admin", got)
+	}
+}
+
+func TestAdminConfigReportsStorageOnce(t *testing.T) {
+	admin, _ := newTestAdmin(t)
+
+	rec := serve(http.HandlerFunc(admin.config), httptest.NewRequest("GET", "/admin/config", nil))
+
+	var body struct {
+		Storage *string `json:"storage"`
+		Server  struct {
+			Storage string `json:"storage"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("got status %d (%v): %s", rec.Code, err, rec.Body)
+	}
+	if body.Storage != nil {
+		t.Errorf("got a top-level storage %q besides server.storage", *body.Storage)
+	}
+	if body.Server.Storage != "memory" {
+		t.Errorf("got server.storage %q, want memory", body.Server.Storage)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// projectsByUser lists the projects a user created or last updated, for
+// attributing changes. Projects written without credentials have no user and
+// can't be found this way.
+func (h *projectHandlers) projectsByUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	user := r.URL.Query().Get("by")
+	if user == "" {
+		writeError(w, http.StatusBadRequest, "by is required, e.g. ?by=admin")
+		return
+	}
+
+	limit, offset, err := parsePage(r, h.cfg.DefaultLimit, h.cfg.MaxLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	projects := []OpenSourceProject{}
+	h.RLock()
+	for _, project := range h.db {
+		if project.CreatedBy == user || project.UpdatedBy == user {
+			projects = append(projects, project.clone())
+		}
+	}
+	h.RUnlock()
+
+	sortedByID(projects)
+	start := min(offset, len(projects))
+	end := min(start+limit, len(projects))
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(projects)))
+	writeJSON(w, r, http.StatusOK, projects[start:end])
+}
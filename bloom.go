@@ -0,0 +1,65 @@
+package main
+
+import "sync/atomic"
+
+const (
+	bloomBits   = 1 << 16
+	bloomHashes = 4
+)
+
+// bloomFilter records which project ids exist so lookups for ids that were
+// never created can be answered without taking the store lock. It can report
+// false positives, never false negatives, and is safe for concurrent use.
+type bloomFilter struct {
+	words [bloomBits / 64]atomic.Uint64
+}
+
+func newBloomFilter(ids ...string) *bloomFilter {
+	f := &bloomFilter{}
+	for _, id := range ids {
+		f.add(id)
+	}
+	return f
+}
+
+func bloomPositions(id string) [bloomHashes]uint64 {
+	// FNV-1a, inlined so a lookup doesn't allocate.
+	sum := uint64(14695981039346656037)
+	for i := 0; i < len(id); i++ {
+		sum ^= uint64(id[i])
+		sum *= 1099511628211
+	}
+
+	// Double hashing: derive every position from the two halves of one hash.
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	var positions [bloomHashes]uint64
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % bloomBits
+	}
+	return positions
+}
+
+func (f *bloomFilter) add(id string) {
+	for _, pos := range bloomPositions(id) {
+		f.words[pos/64].Or(1 << (pos % 64))
+	}
+}
+
+func (f *bloomFilter) mightContain(id string) bool {
+	for _, pos := range bloomPositions(id) {
+		if f.words[pos/64].Load()&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuildBloom replaces the filter with one built from the current store,
+// dropping ids that no longer exist. It must be called with h locked.
+func (h *projectHandlers) rebuildBloom() {
+	ids := make([]string, 0, len(h.db))
+	for id := range h.db {
+		ids = append(ids, id)
+	}
+	h.known.Store(newBloomFilter(ids...))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	f := newBloomFilter()
+	for i := range 1000 {
+		f.add(strconv.Itoa(i))
+	}
+
+	for i := range 1000 {
+		if !f.mightContain(strconv.Itoa(i)) {
+			t.Fatalf("added id %d is reported missing", i)
+		}
+	}
+	misses := 0
+	for i := 1000; i < 2000; i++ {
+		if !f.mightContain(strconv.Itoa(i)) {
+			misses++
+		}
+	}
+	if misses < 990 {
+		t.Errorf("only %d of 1000 ids never added were ruled out", misses)
+	}
+}
+
+// TestGetProjectMissSkipsLock holds the store's write lock, as a slow import
+// would, and expects lookups of ids that were never created to be answered
+// anyway.
+func TestGetProjectMissSkipsLock(t *testing.T) {
+	h, mux := newTestHandlers(t)
+	h.Lock()
+	defer h.Unlock()
+
+	done := make(chan int)
+	go func() {
+		done <- serve(mux, httptest.NewRequest("GET", "/opensource/projects/missing", nil)).Code
+	}()
+	select {
+	case status := <-done:
+		if status != http.StatusNotFound {
+			t.Errorf("got status %d, want 404", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a miss waited for the write lock")
+	}
+}
+
+var benchmarkSink bool
+
+// BenchmarkGetProjectMiss compares how getProject rules out an id that was
+// never created: through the filter, or, when the filter is saturated and
+// rules nothing out, by taking the read lock and looking in the store. The
+// contended cases run while a writer, like a slow import, keeps taking the
+// write lock for 100µs at a time.
+func BenchmarkGetProjectMiss(b *testing.B) {
+	h, _ := newTestHandlers(b)
+	for i := range 10000 {
+		id := strconv.Itoa(i + 10)
+		h.db[id] = OpenSourceProject{ID: id}
+	}
+	h.rebuildBloom()
+	missing := make([]string, 1024)
+	for i := range missing {
+		missing[i] = "missing-" + strconv.Itoa(i)
+	}
+
+	lookups := map[string]func(id string) bool{
+		"filter": func(id string) bool {
+			return h.known.Load().mightContain(id)
+		},
+		"store": func(id string) bool {
+			h.RLock()
+			_, ok := h.db[id]
+			h.RUnlock()
+			return ok
+		},
+	}
+	for _, contended := range []bool{false, true} {
+		for _, name := range []string{"filter", "store"} {
+			lookup := lookups[name]
+			if contended {
+				name += " contended"
+			}
+			b.Run(name, func(b *testing.B) {
+				stop, stopped := make(chan struct{}), make(chan struct{})
+				go func() {
+					defer close(stopped)
+					for contended {
+						select {
+						case <-stop:
+							return
+						default:
+							h.Lock()
+							time.Sleep(100 * time.Microsecond)
+							h.Unlock()
+							runtime.Gosched()
+						}
+					}
+				}()
+
+				b.RunParallel(func(pb *testing.PB) {
+					for i := 0; pb.Next(); i++ {
+						benchmarkSink = lookup(missing[i%len(missing)])
+					}
+				})
+
+				close(stop)
+				<-stopped
+			})
+		}
+	}
+}
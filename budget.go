@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// streamingRoutes hold their connection open by design, so the default budget
+// doesn't apply to them. A per-route budget still does.
+var streamingRoutes = []string{"/opensource/events", "/opensource/ws", "/opensource/projects/{id}/events"}
+
+// flagSlowRequests logs a warning for requests that take longer than their
+// time budget. It can't stop a handler that is already running, so it only
+// reports. Routes are named as in the metrics, and perRoute overrides def;
+// a budget of 0 turns the check off.
+func flagSlowRequests(next http.Handler, def time.Duration, perRoute map[string]time.Duration) http.Handler {
+	if def <= 0 && len(perRoute) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		elapsed := time.Since(start)
+
+		rec, ok := w.(*statusRecorder)
+		if !ok {
+			return
+		}
+
+		budget, ok := perRoute[rec.route]
+		if !ok {
+			if slices.Contains(streamingRoutes, rec.route) {
+				return
+			}
+			budget = def
+		}
+
+		if budget > 0 && elapsed > budget {
+			log.Printf("WARNING: slow request %s %s %s (%s) took %s, over its %s budget", rec.requestID, r.Method, r.URL.Path, rec.route, elapsed, budget)
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type config struct {
+	Addr                string                   `json:"addr"`
+	BasePath            string                   `json:"base_path"`
+	TrustedProxies      []netip.Prefix           `json:"trusted_proxies"`
+	AllowIssuePROverlap bool                     `json:"allow_issue_pr_overlap"`
+	UniqueIssues        bool                     `json:"unique_issues"`
+	UniqueNames         bool                     `json:"unique_names"`
+	MaxListItems        int                      `json:"max_list_items"`
+	MaxJSONDepth        int                      `json:"max_json_depth"`
+	MaxBodyBytes        int64                    `json:"max_body_bytes"`
+	MaxHeaderBytes      int                      `json:"max_header_bytes"`
+	MaxHeaderCount      int                      `json:"max_header_count"`
+	MaxInFlight         int                      `json:"max_in_flight"`
+	DefaultLimit        int                      `json:"default_limit"`
+	DefaultSort         string                   `json:"default_sort"`
+	CollectionMaxAge    time.Duration            `json:"collection_max_age"`
+	MaxLimit            int                      `json:"max_limit"`
+	ShutdownTimeout     time.Duration            `json:"shutdown_timeout"`
+	Storage             string                   `json:"storage"`
+	Formats             []string                 `json:"formats"`
+	DataFile            string                   `json:"data_file"`
+	JSONPretty          bool                     `json:"json_pretty"`
+	TimeFormat          string                   `json:"time_format"`
+	SnapshotInterval    time.Duration            `json:"snapshot_interval"`
+	TLSCert             string                   `json:"tls_cert"`
+	TLSKey              string                   `json:"tls_key"`
+	H2C                 bool                     `json:"h2c"`
+	AdminRoutes         []string                 `json:"admin_routes"`
+	ReadOnly            bool                     `json:"read_only"`
+	AdminRequireHTTPS   bool                     `json:"admin_require_https"`
+	NamePattern         string                   `json:"name_pattern"`
+	DebugBodies         bool                     `json:"debug_bodies"`
+	SelfTest            bool                     `json:"-"`
+	SlowRequestBudget   time.Duration            `json:"slow_request_budget"`
+	RouteBudgets        map[string]time.Duration `json:"route_budgets"`
+
+	nameRegexp  *regexp.Regexp
+	defaultSort sortOrder
+}
+
+func loadConfig() (config, error) {
+	return parseConfig(flag.CommandLine, os.Args[1:])
+}
+
+// parseConfig builds the configuration from the environment and args, defining
+// its flags on fs.
+func parseConfig(fs *flag.FlagSet, args []string) (config, error) {
+	cfg := config{
+		DataFile: os.Getenv("DATA_FILE"),
+	}
+
+	if v := os.Getenv("JSON_PRETTY"); v != "" {
+		pretty, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid JSON_PRETTY %q: must be true or false", v)
+		}
+		cfg.JSONPretty = pretty
+	}
+
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "host:port to listen on")
+	fs.StringVar(&cfg.Storage, "storage", "", "where projects are kept: memory or file (default: file when DATA_FILE is set, memory otherwise)")
+	fs.StringVar(&cfg.TimeFormat, "time-format", "rfc3339", "how project timestamps are written: rfc3339, rfc3339nano or unix (seconds)")
+	fs.StringVar(&cfg.BasePath, "base-path", "", "path prefix the API is mounted under, e.g. /api")
+	trustedProxies := fs.String("trusted-proxies", "", "comma-separated CIDRs of reverse proxies whose X-Forwarded-For/X-Real-IP headers are trusted")
+	fs.BoolVar(&cfg.AllowIssuePROverlap, "allow-issue-pr-overlap", false, "allow the same id to be listed in both open_issues and open_prs")
+	fs.BoolVar(&cfg.UniqueIssues, "unique-issues", false, "reject assigning an open issue to a project when another project already lists it")
+	fs.BoolVar(&cfg.UniqueNames, "unique-names", false, "reject a project name another project already uses, ignoring case")
+	fs.IntVar(&cfg.MaxListItems, "max-list-items", 1000, "maximum number of ids a project can list in open_issues or open_prs")
+	fs.IntVar(&cfg.MaxJSONDepth, "max-json-depth", 16, "deepest nesting of arrays and objects accepted in a request body")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", 1<<20, "maximum size of a request body, after decompression")
+	fs.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", 64<<10, "maximum total size of request headers; larger requests get 431")
+	fs.IntVar(&cfg.MaxHeaderCount, "max-header-count", 100, "maximum number of request header fields; more get 431")
+	fs.IntVar(&cfg.MaxInFlight, "max-in-flight", 0, "maximum number of requests served at once; more get 503 (default: unlimited)")
+	fs.IntVar(&cfg.DefaultLimit, "default-limit", 50, "page size used by listings when no limit is requested")
+	fs.StringVar(&cfg.DefaultSort, "default-sort", "id:asc", `order of the listing when no ?sort= is given, as "field:asc" or "field:desc" on id, name, created_at or updated_at`)
+	fs.IntVar(&cfg.MaxLimit, "max-limit", 500, "largest page size a listing can be asked for; bigger limits are clamped")
+	fs.DurationVar(&cfg.CollectionMaxAge, "collection-max-age", 0, "let shared caches keep listing responses for this long (default: Cache-Control no-store)")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for in-flight requests on shutdown before dropping their connections")
+	fs.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", 5*time.Second, "how often pending changes are flushed to DATA_FILE")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "TLS certificate file; serves HTTPS when set together with -tls-key")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "TLS private key file; serves HTTPS when set together with -tls-cert")
+	fs.BoolVar(&cfg.H2C, "h2c", false, "also accept cleartext HTTP/2 (h2c) connections")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", false, "start in read-only mode: reads are served and writes get 503 until switched off via /admin/read-only")
+	fs.StringVar(&cfg.NamePattern, "name-pattern", ".+", "regular expression project names must match in full")
+	fs.BoolVar(&cfg.DebugBodies, "debug-bodies", false, "log request headers and the first 2KiB of request and response bodies; credentials are redacted")
+	fs.DurationVar(&cfg.SlowRequestBudget, "slow-request-budget", 0, "log a warning for requests taking longer than this (default: off)")
+	routeBudgets := fs.String("route-budgets", "", `comma-separated per-route overrides of -slow-request-budget, e.g. "/opensource/projects/{id}=50ms"`)
+	fs.BoolVar(&cfg.AdminRequireHTTPS, "admin-require-https", false, "refuse admin requests that didn't arrive over HTTPS, directly or via a trusted proxy's X-Forwarded-Proto")
+	formats := fs.String("formats", "json", "comma-separated response formats to serve: json, plus jsonapi and csv; others get 406")
+	fs.BoolVar(&cfg.SelfTest, "selftest", false, "run a create/get/update round trip against an in-memory store, report the result and exit without serving")
+	adminRoutesFlag := fs.String("admin-routes", strings.Join(adminRoutes, ","), `comma-separated admin routes to expose, each "/path" or "METHOD /path"; others answer 404`)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return cfg, fmt.Errorf("invalid -addr %q: %v", cfg.Addr, err)
+	}
+
+	cfg.BasePath = strings.TrimRight(cfg.BasePath, "/")
+	if cfg.BasePath != "" && !strings.HasPrefix(cfg.BasePath, "/") {
+		cfg.BasePath = "/" + cfg.BasePath
+	}
+
+	switch cfg.Storage {
+	case "":
+		cfg.Storage = "memory"
+		if cfg.DataFile != "" {
+			cfg.Storage = "file"
+		}
+	case "memory":
+		cfg.DataFile = ""
+	case "file":
+		if cfg.DataFile == "" {
+			return cfg, fmt.Errorf("-storage=file needs DATA_FILE to name the file")
+		}
+	case "sqlite":
+		return cfg, fmt.Errorf("-storage=sqlite is not available: it needs a SQL driver, and this server is built with the standard library only")
+	default:
+		return cfg, fmt.Errorf("invalid -storage %q: must be memory or file", cfg.Storage)
+	}
+
+	if !slices.Contains(timeFormats, cfg.TimeFormat) {
+		return cfg, fmt.Errorf("invalid -time-format %q: must be one of %s", cfg.TimeFormat, strings.Join(timeFormats, ", "))
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return cfg, fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+
+	if cfg.DefaultLimit <= 0 || cfg.MaxLimit <= 0 {
+		return cfg, fmt.Errorf("-default-limit and -max-limit must be positive")
+	}
+	cfg.DefaultLimit = min(cfg.DefaultLimit, cfg.MaxLimit)
+
+	if cfg.MaxJSONDepth <= 0 {
+		return cfg, fmt.Errorf("-max-json-depth must be positive, but got %d", cfg.MaxJSONDepth)
+	}
+
+	defaultSort, err := parseSortOrder(cfg.DefaultSort)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid -default-sort: %w", err)
+	}
+	cfg.defaultSort = defaultSort
+	cfg.DefaultSort = defaultSort.String()
+
+	if cfg.SnapshotInterval <= 0 {
+		return cfg, fmt.Errorf("-snapshot-interval must be positive, but got %s", cfg.SnapshotInterval)
+	}
+
+	nameRegexp, err := regexp.Compile(`^(?s:` + cfg.NamePattern + `)$`)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid -name-pattern: %w", err)
+	}
+	cfg.nameRegexp = nameRegexp
+
+	for _, cidr := range strings.Split(*trustedProxies, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid -trusted-proxies entry %q: %w", cidr, err)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix.Masked())
+	}
+
+	for _, entry := range strings.Split(*routeBudgets, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		budget, err := time.ParseDuration(value)
+		if !ok || err != nil || budget < 0 {
+			return cfg, fmt.Errorf("invalid -route-budgets entry %q: expected route=duration", entry)
+		}
+		if cfg.RouteBudgets == nil {
+			cfg.RouteBudgets = map[string]time.Duration{}
+		}
+		cfg.RouteBudgets[strings.TrimSpace(route)] = budget
+	}
+
+	cfg.Formats = []string{"json"}
+	for _, format := range strings.Split(*formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || slices.Contains(cfg.Formats, format) {
+			continue
+		}
+		if !slices.Contains(responseFormats, format) {
+			return cfg, fmt.Errorf("invalid -formats entry %q: must be one of %s", format, strings.Join(responseFormats, ", "))
+		}
+		cfg.Formats = append(cfg.Formats, format)
+	}
+
+	for _, entry := range strings.Split(*adminRoutesFlag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path := entry
+		if i := strings.IndexByte(entry, ' '); i >= 0 {
+			path = strings.TrimSpace(entry[i+1:])
+			entry = strings.ToUpper(entry[:i]) + " " + path
+		}
+		if !slices.Contains(adminRoutes, path) {
+			return cfg, fmt.Errorf("invalid -admin-routes entry %q: known admin routes are %s", entry, strings.Join(adminRoutes, ", "))
+		}
+		cfg.AdminRoutes = append(cfg.AdminRoutes, entry)
+	}
+
+	return cfg, nil
+}
+
+// MarshalJSON renders durations as strings such as "30s" instead of
+// nanoseconds.
+func (c config) MarshalJSON() ([]byte, error) {
+	routeBudgets := make(map[string]string, len(c.RouteBudgets))
+	for route, budget := range c.RouteBudgets {
+		routeBudgets[route] = budget.String()
+	}
+
+	type plain config
+	return json.Marshal(struct {
+		plain
+		CollectionMaxAge  string            `json:"collection_max_age"`
+		ShutdownTimeout   string            `json:"shutdown_timeout"`
+		SnapshotInterval  string            `json:"snapshot_interval"`
+		SlowRequestBudget string            `json:"slow_request_budget"`
+		RouteBudgets      map[string]string `json:"route_budgets"`
+	}{
+		plain:             plain(c),
+		CollectionMaxAge:  c.CollectionMaxAge.String(),
+		ShutdownTimeout:   c.ShutdownTimeout.String(),
+		SnapshotInterval:  c.SnapshotInterval.String(),
+		SlowRequestBudget: c.SlowRequestBudget.String(),
+		RouteBudgets:      routeBudgets,
+	})
+}
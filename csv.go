@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getCSV exports projects for spreadsheets, ordered by id. It takes the same
+// ?ids= filter as the collection, and from, to and since on updated_at like
+// /recent, but is never paginated.
+func (h *projectHandlers) getCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	if !h.formatEnabled("csv") {
+		h.writeFormatDisabled(w, "csv")
+		return
+	}
+
+	window, err := parseTimeRange(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ids map[string]bool
+	if list := r.URL.Query().Get("ids"); list != "" {
+		ids = map[string]bool{}
+		for _, id := range strings.Split(list, ",") {
+			ids[strings.TrimSpace(id)] = true
+		}
+	}
+
+	projects := []OpenSourceProject{}
+	h.RLock()
+	for id, project := range h.db {
+		if (ids == nil || ids[id]) && window.contains(project.UpdatedAt.Time) {
+			projects = append(projects, project)
+		}
+	}
+	h.RUnlock()
+	sortedByID(projects)
+
+	h.cacheCollection(w, r)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="projects.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "name", "open_issues_count", "open_prs_count", "created_at", "updated_at"})
+	for _, p := range projects {
+		out.Write([]string{
+			p.ID,
+			csvSafe(p.Name),
+			strconv.Itoa(len(p.OpenIssues)),
+			strconv.Itoa(len(p.OpenPRs)),
+			p.CreatedAt.UTC().Format(time.RFC3339),
+			p.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+}
+
+// csvSafe stops spreadsheets from running a name as a formula, by quoting
+// values that start with a formula character the way Excel expects.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	eventBufferSize   = 16
+	keepAliveInterval = 15 * time.Second
+)
+
+type projectEvent struct {
+	Type    string            `json:"type"`
+	Project OpenSourceProject `json:"project"`
+}
+
+// eventBroker fans project events out to subscribers. Each subscriber gets a
+// buffered channel; one that falls behind far enough to fill it is dropped
+// rather than allowed to block the writer publishing the event.
+type eventBroker struct {
+	sync.Mutex
+	subs   map[chan projectEvent]string
+	closed bool
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: map[chan projectEvent]string{}}
+}
+
+// subscribe returns a channel receiving the events of project id, or of every
+// project when id is empty. The channel is closed by unsubscribe, when the
+// subscriber is dropped for being too slow, or when the broker shuts down.
+func (b *eventBroker) subscribe(id string) (<-chan projectEvent, func()) {
+	b.Lock()
+	defer b.Unlock()
+
+	ch := make(chan projectEvent, eventBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = id
+
+	return ch, func() {
+		b.Lock()
+		defer b.Unlock()
+
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBroker) publish(event projectEvent) {
+	b.Lock()
+	defer b.Unlock()
+
+	for ch, id := range b.subs {
+		if id != "" && id != event.Project.ID {
+			continue
+		}
+
+		select {
+		case ch <- event:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBroker) close() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func (h *projectHandlers) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	h.RLock()
+	_, ok := h.db[id]
+	h.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
+		return
+	}
+
+	events, unsubscribe := h.events.subscribe(id)
+	defer unsubscribe()
+
+	writeEventStream(w, r, events, "")
+}
+
+// streamAllEvents serves the change feed of every project, optionally
+// restricted to one event type with ?type=.
+func (h *projectHandlers) streamAllEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, r, "GET")
+		return
+	}
+
+	events, unsubscribe := h.events.subscribe("")
+	defer unsubscribe()
+
+	writeEventStream(w, r, events, r.URL.Query().Get("type"))
+}
+
+func writeEventStream(w http.ResponseWriter, r *http.Request, events <-chan projectEvent, eventType string) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	releaseInFlightSlot(r)
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if eventType != "" && event.Type != eventType {
+				continue
+			}
+			data, err := json.Marshal(event.Project)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
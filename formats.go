@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// responseFormats are the representations -formats can enable. Plain JSON is
+// always on, since errors and every other endpoint use it.
+var responseFormats = []string{"json", "jsonapi", "csv"}
+
+func (h *projectHandlers) formatEnabled(format string) bool {
+	return slices.Contains(h.cfg.Formats, format)
+}
+
+// useJSONAPI reports whether a project response should be a JSON:API
+// document. A client that asks only for JSON:API while it is disabled gets 406
+// and ok is false; one that also accepts plain JSON gets that instead.
+func (h *projectHandlers) useJSONAPI(w http.ResponseWriter, r *http.Request) (jsonAPI, ok bool) {
+	varyOnAccept(w)
+	if !wantsJSONAPI(r) {
+		return false, true
+	}
+	if h.formatEnabled("jsonapi") {
+		return true, true
+	}
+	if acceptsPlainJSON(r.Header.Get("Accept")) {
+		return false, true
+	}
+	h.writeFormatDisabled(w, "jsonapi")
+	return false, false
+}
+
+func acceptsPlainJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+func (h *projectHandlers) writeFormatDisabled(w http.ResponseWriter, format string) {
+	writeError(w, http.StatusNotAcceptable, fmt.Sprintf("%s responses are disabled; enabled formats are %s", format, strings.Join(h.cfg.Formats, ", ")))
+}
@@ -1,3 +1,3 @@
 module vanilla-go-rest-api
 
-go 1.21.1
+go 1.24
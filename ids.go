@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// idList is a list of issue or PR ids. Clients may send each id as a JSON
+// string or number; numbers are kept in the exact form they were written in.
+type idList []string
+
+func (l *idList) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	ids := make(idList, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &ids[i]); err == nil {
+			continue
+		}
+
+		var n json.Number
+		if err := json.Unmarshal(item, &n); err != nil {
+			return fmt.Errorf("id %d: expected a string or number, but got %s", i, item)
+		}
+		ids[i] = n.String()
+	}
+
+	*l = ids
+	return nil
+}
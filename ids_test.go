@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestIDListUnmarshal(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    idList
+		wantErr bool
+	}{
+		{data: `["1", 2, "abc"]`, want: idList{"1", "2", "abc"}},
+		{data: `[10000000000000000000001, -3, 1.5]`, want: idList{"10000000000000000000001", "-3", "1.5"}},
+		{data: `[]`, want: idList{}},
+		{data: `null`, want: nil},
+		{data: `["1", true]`, wantErr: true},
+		{data: `["1", {"id": 2}]`, wantErr: true},
+		{data: `"1"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got idList
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (!slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil)) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostMixedIDs(t *testing.T) {
+	_, mux := newTestHandlers(t)
+
+	rec := serve(mux, jsonRequest("POST", "/opensource/projects", `{"name": "Mixed", "open_issues": ["1", 2, "abc"], "open_prs": [7]}`))
+	var project OpenSourceProject
+	if err := json.Unmarshal(rec.Body.Bytes(), &project); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("got status %d (%v): %s", rec.Code, err, rec.Body)
+	}
+	if want := []string{"1", "2", "abc"}; !slices.Equal(project.OpenIssues, want) {
+		t.Errorf("got issues %q, want %q", project.OpenIssues, want)
+	}
+	if want := []string{"7"}; !slices.Equal(project.OpenPRs, want) {
+		t.Errorf("got PRs %q, want %q", project.OpenPRs, want)
+	}
+}
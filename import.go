@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxImportProjects = 10000
+
+type importProjectReq struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	OpenIssues idList `json:"open_issues"`
+	OpenPRs    idList `json:"open_prs"`
+}
+
+type importResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// importProjects loads a batch of projects with their ids. ?on_conflict=
+// decides what happens to ids that already exist: fail (the default) rejects
+// the whole import with 409, skip leaves the stored project alone, and
+// overwrite replaces it. Nothing is applied until every item has been checked,
+// including against -unique-names and -unique-issues as the store would be
+// afterwards.
+func (h *projectHandlers) importProjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+
+	onConflict := r.URL.Query().Get("on_conflict")
+	switch onConflict {
+	case "":
+		onConflict = "fail"
+	case "fail", "skip", "overwrite":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("on_conflict must be fail, skip or overwrite, but got %s", onConflict))
+		return
+	}
+
+	bodyBytes, status, err := h.readBody(w, r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	if ct := r.Header.Get("content-type"); ct != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("need content-type application-json, but got %s", ct))
+		return
+	}
+
+	var items []importProjectReq
+	err = checkJSONShape(bodyBytes, h.cfg.MaxJSONDepth, max(h.cfg.MaxListItems, maxImportProjects))
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, &items)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invalid := []invalidProject{}
+	seen := map[string]bool{}
+	for i, item := range items {
+		var errs validationErrors
+		if problem := h.clientIDProblem(item.ID); problem != "" {
+			errs.add("id", "%s", problem)
+		} else if seen[item.ID] {
+			errs.add("id", "is imported more than once")
+		}
+		seen[item.ID] = true
+
+		req := CreateOpenSourceProjectReq{Name: item.Name, OpenIssues: item.OpenIssues, OpenPRs: item.OpenPRs}
+		errs = append(errs, req.Validate(h.cfg)...)
+		if len(errs) > 0 {
+			id := item.ID
+			if id == "" {
+				id = fmt.Sprintf("[%d]", i)
+			}
+			invalid = append(invalid, invalidProject{ID: id, Errors: errs})
+		}
+	}
+	if len(invalid) > 0 {
+		writeJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"invalid": invalid})
+		return
+	}
+
+	user := adminUserFrom(r)
+	now := time.Now()
+	result := importResult{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+
+	h.Lock()
+	var conflicts []string
+	for _, item := range items {
+		if _, ok := h.db[item.ID]; ok {
+			conflicts = append(conflicts, item.ID)
+		}
+	}
+	if len(conflicts) > 0 && onConflict == "fail" {
+		h.Unlock()
+		sort.Slice(conflicts, func(i, j int) bool { return lessID(conflicts[i], conflicts[j]) })
+		writeJSON(w, r, http.StatusConflict, map[string]any{
+			"error": fmt.Sprintf("%d imported ids already exist; use on_conflict=skip or overwrite", len(conflicts)),
+			"ids":   conflicts,
+		})
+		return
+	}
+
+	// The import is checked against a copy of the store as it would look
+	// afterwards and only swapped in once that passes.
+	next := make(map[string]OpenSourceProject, len(h.db)+len(items))
+	for id, project := range h.db {
+		next[id] = project
+	}
+	var changed []OpenSourceProject
+	imported := map[string]bool{}
+	for _, item := range items {
+		previous, exists := h.db[item.ID]
+		if exists && onConflict == "skip" {
+			result.Skipped = append(result.Skipped, item.ID)
+			continue
+		}
+
+		project := NewOpenSourceProject(CreateOpenSourceProjectReq{Name: item.Name, OpenIssues: item.OpenIssues, OpenPRs: item.OpenPRs}, item.ID, now)
+		project.CreatedBy = user
+		project.UpdatedBy = user
+		if exists {
+			project.Version = previous.Version + 1
+			project.CreatedAt = previous.CreatedAt
+			project.CreatedBy = previous.CreatedBy
+			result.Updated = append(result.Updated, item.ID)
+		} else {
+			result.Created = append(result.Created, item.ID)
+		}
+		next[item.ID] = project
+		changed = append(changed, project)
+		imported[item.ID] = true
+	}
+
+	if problem := h.uniquenessProblem(next, imported); problem != "" {
+		h.Unlock()
+		writeError(w, http.StatusConflict, problem)
+		return
+	}
+
+	previous := h.db
+	h.db = next
+	for i, project := range changed {
+		if old, ok := previous[project.ID]; !ok || old.Name != project.Name {
+			project.Slug = h.uniqueSlug(project.Name, project.ID)
+		} else {
+			project.Slug = old.Slug
+		}
+		h.db[project.ID] = project
+		changed[i] = project.clone()
+	}
+	h.rebuildIssueIndex()
+	h.rebuildBloom()
+	if len(changed) > 0 {
+		h.markDirty()
+	}
+	h.Unlock()
+
+	for _, project := range changed {
+		eventType := "created"
+		if project.Version > 1 {
+			eventType = "updated"
+		}
+		h.events.publish(projectEvent{Type: eventType, Project: project})
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// uniquenessProblem describes the first name or issue that an imported
+// project would share with another project in db while -unique-names or
+// -unique-issues forbids it. Clashes the store already had are left alone.
+func (h *projectHandlers) uniquenessProblem(db map[string]OpenSourceProject, imported map[string]bool) string {
+	ids := make([]string, 0, len(db))
+	for id := range db {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return lessID(ids[i], ids[j]) })
+
+	names := map[string]string{}
+	issues := map[string]string{}
+	for _, id := range ids {
+		project := db[id]
+		if h.cfg.UniqueNames {
+			key := strings.ToLower(strings.TrimSpace(project.Name))
+			if owner, ok := names[key]; !ok {
+				names[key] = id
+			} else if imported[owner] || imported[id] {
+				return fmt.Sprintf("projects %s and %s would both be named %q", owner, id, project.Name)
+			}
+		}
+		if h.cfg.UniqueIssues {
+			for _, issue := range project.OpenIssues {
+				if owner, ok := issues[issue]; !ok {
+					issues[issue] = id
+				} else if owner != id && (imported[owner] || imported[id]) {
+					return fmt.Sprintf("issue %s would belong to both project %s and project %s", issue, owner, id)
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// The issue index maps each open issue id to the project that lists it. It is
+// only maintained with -unique-issues, and every function here must be called
+// with h locked.
+
+func (h *projectHandlers) rebuildIssueIndex() {
+	if !h.cfg.UniqueIssues {
+		return
+	}
+
+	ids := make([]string, 0, len(h.db))
+	for id := range h.db {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return lessID(ids[i], ids[j]) })
+
+	h.issueOwners = map[string]string{}
+	for _, id := range ids {
+		for _, issue := range h.db[id].OpenIssues {
+			if owner, ok := h.issueOwners[issue]; ok && owner != id {
+				log.Printf("WARNING: issue %s is listed by projects %s and %s; keeping it on %s", issue, owner, id, owner)
+				continue
+			}
+			h.issueOwners[issue] = id
+		}
+	}
+}
+
+// issueConflicts returns the issues that project id can't list because another
+// project already does, mapped to that project's id.
+func (h *projectHandlers) issueConflicts(id string, issues []string) map[string]string {
+	if !h.cfg.UniqueIssues {
+		return nil
+	}
+
+	conflicts := map[string]string{}
+	for _, issue := range issues {
+		if owner, ok := h.issueOwners[issue]; ok && owner != id {
+			conflicts[issue] = owner
+		}
+	}
+	return conflicts
+}
+
+// reindexIssues moves project id's entries in the index from old to issues.
+// Issues another project already owns are left with that project.
+func (h *projectHandlers) reindexIssues(id string, old, issues []string) {
+	if !h.cfg.UniqueIssues {
+		return
+	}
+
+	for _, issue := range old {
+		if h.issueOwners[issue] == id {
+			delete(h.issueOwners, issue)
+		}
+	}
+	for _, issue := range issues {
+		if _, ok := h.issueOwners[issue]; !ok {
+			h.issueOwners[issue] = id
+		}
+	}
+}
+
+func describeIssueConflicts(conflicts map[string]string) string {
+	issues := make([]string, 0, len(conflicts))
+	for issue := range conflicts {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+
+	parts := make([]string, len(issues))
+	for i, issue := range issues {
+		parts[i] = fmt.Sprintf("issue %s belongs to project %s", issue, conflicts[issue])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func writeIssueConflicts(w http.ResponseWriter, r *http.Request, conflicts map[string]string) {
+	writeJSON(w, r, http.StatusConflict, map[string]any{
+		"error":     describeIssueConflicts(conflicts),
+		"conflicts": conflicts,
+	})
+}
+
+type issueUsage struct {
+	Issues map[string][]string `json:"issues"`
+	PRs    map[string][]string `json:"prs"`
+}
+
+// issueUsage maps every issue and PR id to the projects listing it, so ids
+// shared by several projects stand out. It works whether or not
+// -unique-issues is set.
+func (h *projectHandlers) issueUsage(w http.ResponseWriter, r *http.Request) {
+	usage := issueUsage{Issues: map[string][]string{}, PRs: map[string][]string{}}
+
+	h.RLock()
+	for id, project := range h.db {
+		for _, issue := range project.OpenIssues {
+			usage.Issues[issue] = append(usage.Issues[issue], id)
+		}
+		for _, pr := range project.OpenPRs {
+			usage.PRs[pr] = append(usage.PRs[pr], id)
+		}
+	}
+	h.RUnlock()
+
+	for _, ids := range usage.Issues {
+		sort.Slice(ids, func(i, j int) bool { return lessID(ids[i], ids[j]) })
+	}
+	for _, ids := range usage.PRs {
+		sort.Slice(ids, func(i, j int) bool { return lessID(ids[i], ids[j]) })
+	}
+
+	writeJSON(w, r, http.StatusOK, usage)
+}
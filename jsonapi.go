@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const jsonAPIMediaType = "application/vnd.api+json"
+
+type jsonAPIAttributes struct {
+	Name       string    `json:"name"`
+	Slug       string    `json:"slug"`
+	OpenIssues []string  `json:"open_issues"`
+	OpenPRs    []string  `json:"open_prs"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	UpdatedBy  string    `json:"updated_by,omitempty"`
+	Version    int       `json:"version"`
+	CreatedAt  timestamp `json:"created_at"`
+	UpdatedAt  timestamp `json:"updated_at"`
+}
+
+type jsonAPIResource struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Attributes jsonAPIAttributes `json:"attributes"`
+	Links      jsonAPILinks      `json:"links"`
+}
+
+type jsonAPILinks struct {
+	Self string `json:"self"`
+}
+
+type jsonAPIDocument struct {
+	Data  any          `json:"data"`
+	Links jsonAPILinks `json:"links"`
+}
+
+func wantsJSONAPI(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), jsonAPIMediaType)
+}
+
+// representationETag tells the JSON:API form of a project or listing apart
+// from the plain JSON one, so a cache or conditional GET never answers a
+// request for one with the other.
+func representationETag(etag string, jsonAPI bool) string {
+	if !jsonAPI {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + `-jsonapi"`
+}
+
+// varyOnAccept marks a response as negotiated on the Accept header. That holds
+// even while JSON:API is disabled, since Accept then decides between plain
+// JSON and 406.
+func varyOnAccept(w http.ResponseWriter) {
+	if !slices.Contains(w.Header().Values("Vary"), "Accept") {
+		w.Header().Add("Vary", "Accept")
+	}
+}
+
+func (h *projectHandlers) jsonAPIResource(p OpenSourceProject) jsonAPIResource {
+	return jsonAPIResource{
+		Type: "projects",
+		ID:   p.ID,
+		Attributes: jsonAPIAttributes{
+			Name:       p.Name,
+			Slug:       p.Slug,
+			OpenIssues: p.OpenIssues,
+			OpenPRs:    p.OpenPRs,
+			CreatedBy:  p.CreatedBy,
+			UpdatedBy:  p.UpdatedBy,
+			Version:    p.Version,
+			CreatedAt:  p.CreatedAt,
+			UpdatedAt:  p.UpdatedAt,
+		},
+		Links: jsonAPILinks{Self: h.location(p.ID)},
+	}
+}
+
+// writeProjectBody writes one project as plain JSON, or as a JSON:API document
+// when the client asked for it and -formats enables it, with the ETag of the
+// representation it chose.
+func (h *projectHandlers) writeProjectBody(w http.ResponseWriter, r *http.Request, status int, project OpenSourceProject) {
+	jsonAPI := wantsJSONAPI(r) && h.formatEnabled("jsonapi")
+	varyOnAccept(w)
+	w.Header().Set("ETag", representationETag(project.ETag(), jsonAPI))
+
+	if !jsonAPI {
+		writeJSON(w, r, status, project)
+		return
+	}
+
+	w.Header().Set("content-type", jsonAPIMediaType)
+	writeJSON(w, r, status, jsonAPIDocument{
+		Data:  h.jsonAPIResource(project),
+		Links: jsonAPILinks{Self: r.RequestURI},
+	})
+}
+
+// writeProjects is writeProjectBody for listings.
+func (h *projectHandlers) writeProjects(w http.ResponseWriter, r *http.Request, projects []OpenSourceProject) {
+	jsonAPI, ok := h.useJSONAPI(w, r)
+	if !ok {
+		return
+	}
+	h.cacheCollection(w, r)
+
+	etag := representationETag(collectionETag(projects, w.Header().Get("X-Total-Count")), jsonAPI)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if !jsonAPI {
+		writeJSON(w, r, http.StatusOK, projects)
+		return
+	}
+
+	resources := make([]jsonAPIResource, len(projects))
+	for i, project := range projects {
+		resources[i] = h.jsonAPIResource(project)
+	}
+
+	w.Header().Set("content-type", jsonAPIMediaType)
+	writeJSON(w, r, http.StatusOK, jsonAPIDocument{
+		Data:  resources,
+		Links: jsonAPILinks{Self: r.RequestURI},
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRepresentationsHaveTheirOwnETags makes sure a client holding the plain
+// JSON ETag is never told its copy is current when it asks for JSON:API, and
+// the other way around.
+func TestRepresentationsHaveTheirOwnETags(t *testing.T) {
+	_, mux := newTestHandlers(t, "-formats=json,jsonapi")
+	get := func(path, accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", accept)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return serve(mux, req)
+	}
+
+	for _, path := range []string{"/opensource/projects/1", "/opensource/projects"} {
+		plain := get(path, "application/json", "")
+		jsonAPI := get(path, jsonAPIMediaType, "")
+
+		for _, rec := range []*httptest.ResponseRecorder{plain, jsonAPI} {
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("%s: got Vary %q, want Accept", path, got)
+			}
+		}
+		plainETag, jsonAPIETag := plain.Header().Get("ETag"), jsonAPI.Header().Get("ETag")
+		if plainETag == "" || plainETag == jsonAPIETag {
+			t.Fatalf("%s: got ETag %q for JSON and %q for JSON:API, want two different ones", path, plainETag, jsonAPIETag)
+		}
+
+		if rec := get(path, jsonAPIMediaType, plainETag); rec.Code != http.StatusOK {
+			t.Errorf("%s: JSON:API request with the JSON ETag got status %d, want 200", path, rec.Code)
+		}
+		if rec := get(path, "application/json", jsonAPIETag); rec.Code != http.StatusOK {
+			t.Errorf("%s: JSON request with the JSON:API ETag got status %d, want 200", path, rec.Code)
+		}
+		if rec := get(path, jsonAPIMediaType, jsonAPIETag); rec.Code != http.StatusNotModified {
+			t.Errorf("%s: JSON:API request with its own ETag got status %d, want 304", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// resolvePointer evaluates an RFC 6901 JSON Pointer such as /open_issues/0
+// against doc, a value decoded by encoding/json.
+func resolvePointer(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must be empty or start with /", pointer)
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		switch v := doc.(type) {
+		case map[string]any:
+			value, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("JSON pointer %q: no member %q", pointer, token)
+			}
+			doc = value
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+				return nil, fmt.Errorf("JSON pointer %q: %q is not an array index", pointer, token)
+			}
+			if i >= len(v) {
+				return nil, fmt.Errorf("JSON pointer %q: index %d is out of range for %d items", pointer, i, len(v))
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("JSON pointer %q: can't look up %q in a %s", pointer, token, jsonType(v))
+		}
+	}
+	return doc, nil
+}
+
+// writeProjectField answers ?field= with the part of the project the pointer
+// names. It reports whether the request asked for a field.
+func writeProjectField(w http.ResponseWriter, r *http.Request, project OpenSourceProject) bool {
+	query := r.URL.Query()
+	if !query.Has("field") {
+		return false
+	}
+
+	data, err := json.Marshal(project)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+
+	value, err := resolvePointer(doc, query.Get("field"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return true
+	}
+
+	writeJSON(w, r, http.StatusOK, value)
+	return true
+}
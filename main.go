@@ -1,209 +1,823 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+const (
+	maxBatchIDs       = 100
+	maxRecentProjects = 50
+	idempotencyKeyTTL = 24 * time.Hour
+)
+
 type OpenSourceProject struct {
 	ID         string    `json:"id"`
 	Name       string    `json:"name"`
+	Slug       string    `json:"slug"`
 	OpenIssues []string  `json:"open_issues"`
 	OpenPRs    []string  `json:"open_prs"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	UpdatedBy  string    `json:"updated_by,omitempty"`
+	Version    int       `json:"version"`
+	CreatedAt  timestamp `json:"created_at"`
+	UpdatedAt  timestamp `json:"updated_at"`
+}
+
+// clone returns a copy of p whose slices don't share backing arrays with the
+// stored project, so it can be marshaled outside the lock.
+func (p OpenSourceProject) clone() OpenSourceProject {
+	p.OpenIssues = nonNil(slices.Clone(p.OpenIssues))
+	p.OpenPRs = nonNil(slices.Clone(p.OpenPRs))
+	return p
+}
+
+// nonNil makes empty lists marshal as [] instead of null.
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// NewOpenSourceProject builds the first version of a project from a create
+// request. Callers set the store-dependent Slug and the CreatedBy and UpdatedBy
+// users, which are the same on every create.
+func NewOpenSourceProject(req CreateOpenSourceProjectReq, id string, now time.Time) OpenSourceProject {
+	return OpenSourceProject{
+		ID:         id,
+		Name:       strings.TrimSpace(req.Name),
+		OpenIssues: nonNil(slices.Clone(req.OpenIssues)),
+		OpenPRs:    nonNil(slices.Clone(req.OpenPRs)),
+		Version:    1,
+		CreatedAt:  timestamp{now},
+		UpdatedAt:  timestamp{now},
+	}
+}
+
+// ETag is derived from the revision counter rather than UpdatedAt so that it
+// changes on every mutation, even when two land within the same clock tick.
+func (p OpenSourceProject) ETag() string {
+	return fmt.Sprintf(`"%s-%d"`, p.ID, p.Version)
 }
 
 type CreateOpenSourceProjectReq struct {
-	Name       string   `json:"name"`
-	OpenIssues []string `json:"open_issues"`
-	OpenPRs    []string `json:"open_prs"`
+	Name       string `json:"name"`
+	OpenIssues idList `json:"open_issues"`
+	OpenPRs    idList `json:"open_prs"`
+}
+
+// idempotentResult keeps the project as it was created, so a retry gets the
+// same response even after the project has been changed since.
+type idempotentResult struct {
+	project OpenSourceProject
+	status  int
+	expires time.Time
 }
 
 type projectHandlers struct {
-	sync.Mutex
-	db map[string]OpenSourceProject
+	sync.RWMutex
+	db              map[string]OpenSourceProject
+	idempotencyKeys map[string]idempotentResult
+	issueOwners     map[string]string
+	events          *eventBroker
+	known           atomic.Pointer[bloomFilter]
+	routeTable      []route
+	admin           *adminPortal
+	cfg             config
+
+	// dirty is set when the store has changes that haven't been flushed to
+	// the data file yet. flushMu serializes the flushes themselves.
+	dirty   atomic.Bool
+	flushMu sync.Mutex
+
+	// lastAccessed maps project ids to the time they were last read. It is
+	// kept outside the RWMutex so recording an access never blocks readers.
+	lastAccessed sync.Map
 }
 
-func (h *projectHandlers) projects(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		h.getAll(w, r)
-		return
-	case "POST":
-		h.post(w, r)
-		return
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte("Method not allowed"))
-	}
+type projectStats struct {
+	ID             string     `json:"id"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
 }
 
 func (h *projectHandlers) post(w http.ResponseWriter, r *http.Request) {
-	bodyBytes, err := io.ReadAll(r.Body)
-	defer r.Body.Close()
+	bodyBytes, status, err := h.readBody(w, r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
+		writeError(w, status, err.Error())
 		return
 	}
 
 	ct := r.Header.Get("content-type")
 	if ct != "application/json" {
-		w.WriteHeader(http.StatusUnsupportedMediaType)
-		w.Write([]byte(fmt.Sprintf("need content-type application-json, but got %s", ct)))
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("need content-type application-json, but got %s", ct))
 		return
 	}
 
-	var body CreateOpenSourceProjectReq
-	err = json.Unmarshal(bodyBytes, &body)
+	body, errs, err := parseCreateReq(bodyBytes, h.cfg)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.Error()))
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	openSourceProject := OpenSourceProject{
-		ID:         fmt.Sprint(len(h.db) + 1),
-		Name:       body.Name,
-		OpenIssues: body.OpenIssues,
-		OpenPRs:    body.OpenPRs,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	if len(errs) > 0 {
+		writeValidationErrors(w, http.StatusUnprocessableEntity, errs)
+		return
 	}
 
+	createdBy := h.admin.attribute(r)
+	key := r.Header.Get("Idempotency-Key")
+
 	h.Lock()
-	h.db[openSourceProject.ID] = openSourceProject
+	if result, ok := h.idempotencyKeys[key]; ok && key != "" && time.Now().Before(result.expires) {
+		project := result.project.clone()
+		h.Unlock()
+
+		w.Header().Set("Location", h.location(project.ID))
+		w.Header().Set("ETag", project.ETag())
+		h.writeWriteResult(w, r, result.status, project)
+		return
+	}
+
+	id := h.nextID()
+	if owner, taken := h.nameOwner(id, body.Name); taken {
+		h.Unlock()
+		writeNameConflict(w, r, body.Name, owner)
+		return
+	}
+	if conflicts := h.issueConflicts(id, body.OpenIssues); len(conflicts) > 0 {
+		h.Unlock()
+		writeIssueConflicts(w, r, conflicts)
+		return
+	}
+
+	openSourceProject := NewOpenSourceProject(body, id, time.Now())
+	openSourceProject.Slug = h.uniqueSlug(openSourceProject.Name, "")
+	openSourceProject.CreatedBy = createdBy
+	openSourceProject.UpdatedBy = createdBy
+	h.insert(openSourceProject)
+
+	if key != "" {
+		h.rememberIdempotencyKey(key, openSourceProject.clone(), http.StatusCreated)
+	}
 	h.Unlock()
+
+	h.events.publish(projectEvent{Type: "created", Project: openSourceProject.clone()})
+
+	w.Header().Set("Location", h.location(openSourceProject.ID))
+	w.Header().Set("ETag", openSourceProject.ETag())
+	h.writeWriteResult(w, r, http.StatusCreated, openSourceProject)
+}
+
+// nextID returns the first free numeric id from len(db)+1 up, skipping ids
+// clients picked themselves with PUT. It must be called with h locked.
+func (h *projectHandlers) nextID() string {
+	for n := len(h.db) + 1; ; n++ {
+		id := strconv.Itoa(n)
+		if _, taken := h.db[id]; !taken {
+			return id
+		}
+	}
+}
+
+// insert adds a new project to the store and its indexes. It must be called
+// with h locked.
+func (h *projectHandlers) insert(project OpenSourceProject) {
+	h.db[project.ID] = project
+	h.known.Load().add(project.ID)
+	h.reindexIssues(project.ID, nil, project.OpenIssues)
+	h.markDirty()
+}
+
+// rememberIdempotencyKey must be called with h locked.
+func (h *projectHandlers) rememberIdempotencyKey(key string, project OpenSourceProject, status int) {
+	now := time.Now()
+	for k, result := range h.idempotencyKeys {
+		if now.After(result.expires) {
+			delete(h.idempotencyKeys, k)
+		}
+	}
+
+	h.idempotencyKeys[key] = idempotentResult{
+		project: project,
+		status:  status,
+		expires: now.Add(idempotencyKeyTTL),
+	}
+}
+
+func (h *projectHandlers) location(id string) string {
+	return h.cfg.BasePath + "/opensource/projects/" + id
+}
+
+// readBody reads the request body, transparently decompressing it when it is
+// sent with Content-Encoding: gzip. The size limit applies both to the bytes
+// on the wire and to the decompressed body. On failure it also returns the
+// status to respond with.
+func (h *projectHandlers) readBody(w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	defer r.Body.Close()
+	body := http.MaxBytesReader(w, r.Body, h.cfg.MaxBodyBytes)
+
+	var reader io.Reader = body
+	compressed := false
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("malformed gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, h.cfg.MaxBodyBytes+1)
+		compressed = true
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content-encoding %s", encoding)
+	}
+
+	bodyBytes, err := io.ReadAll(reader)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr) || int64(len(bodyBytes)) > h.cfg.MaxBodyBytes:
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", h.cfg.MaxBodyBytes)
+	case err != nil && compressed:
+		return nil, http.StatusBadRequest, fmt.Errorf("malformed gzip body: %w", err)
+	case err != nil:
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return bodyBytes, 0, nil
 }
 
 func (h *projectHandlers) getAll(w http.ResponseWriter, r *http.Request) {
-	projects := make([]OpenSourceProject, len(h.db))
+	if ids := r.URL.Query().Get("ids"); ids != "" {
+		h.getByIDs(w, r, ids)
+		return
+	}
 
-	h.Lock()
-	i := 0
+	h.RLock()
+	projects := make([]OpenSourceProject, 0, len(h.db))
 	for _, project := range h.db {
-		projects[i] = project
-		i++
+		projects = append(projects, project.clone())
 	}
-	h.Unlock()
+	h.RUnlock()
 
-	jsonBytes, err := json.Marshal(projects)
-	fmt.Printf("json bytes: %v", jsonBytes)
+	limit, offset, err := parsePage(r, h.cfg.DefaultLimit, h.cfg.MaxLimit)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	w.Header().Add("content-type", "application/json")
-	w.Write(jsonBytes)
+	order := h.cfg.defaultSort
+	if s := r.URL.Query().Get("sort"); s != "" {
+		if order, err = parseSortOrder(s); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	order.apply(projects)
+	start := min(offset, len(projects))
+	end := min(start+limit, len(projects))
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(projects)))
+	h.writeProjects(w, r, projects[start:end])
+}
+
+func sortedByID(projects []OpenSourceProject) []OpenSourceProject {
+	sort.Slice(projects, func(i, j int) bool {
+		return lessID(projects[i].ID, projects[j].ID)
+	})
+	return projects
 }
 
-func (h *projectHandlers) getProject(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.String(), "/")
-	if len(parts) != 4 {
-		w.WriteHeader(http.StatusBadRequest)
+// lessID orders numeric ids by value, so "10" sorts after "9".
+func lessID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (h *projectHandlers) getByIDs(w http.ResponseWriter, r *http.Request, list string) {
+	projects, err := h.lookupIDs(list)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id := parts[3]
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(projects)))
+	h.writeProjects(w, r, projects)
+}
 
-	h.Lock()
+// lookupIDs returns the projects among the comma-separated ids in list that
+// exist, in the order they were asked for and without repeats.
+func (h *projectHandlers) lookupIDs(list string) ([]OpenSourceProject, error) {
+	ids := strings.Split(list, ",")
+	if len(ids) > maxBatchIDs {
+		return nil, fmt.Errorf("at most %d ids can be requested at once, but got %d", maxBatchIDs, len(ids))
+	}
+
+	projects := []OpenSourceProject{}
+	seen := map[string]bool{}
+
+	h.RLock()
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if project, ok := h.db[id]; ok {
+			projects = append(projects, project.clone())
+		}
+	}
+	h.RUnlock()
+
+	return projects, nil
+}
+
+// total counts the projects the collection GET would list for r, which is only
+// those named by ?ids= when it is given.
+func (h *projectHandlers) total(r *http.Request) (int, error) {
+	if list := r.URL.Query().Get("ids"); list != "" {
+		projects, err := h.lookupIDs(list)
+		return len(projects), err
+	}
+
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.db), nil
+}
+
+func (h *projectHandlers) head(w http.ResponseWriter, r *http.Request) {
+	total, err := h.total(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+}
+
+// register mounts the project routes under both /opensource/projects and the
+// shorter /projects alias.
+func (h *projectHandlers) register(mux *http.ServeMux) {
+	api := rejectWritesWhenReadOnly(h)
+	for _, prefix := range []string{"/opensource/projects", "/projects"} {
+		mux.Handle(prefix, http.StripPrefix(prefix, api))
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, api))
+		mux.HandleFunc(prefix+".csv", h.getCSV)
+	}
+}
+
+func (h *projectHandlers) schema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("content-type", "application/schema+json")
+	w.Write([]byte(createProjectSchema))
+}
+
+func (h *projectHandlers) example(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, exampleCreateProjectReq)
+}
+
+func (h *projectHandlers) getRecent(w http.ResponseWriter, r *http.Request) {
+	window, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	projects := []OpenSourceProject{}
+
+	h.RLock()
+	for _, project := range h.db {
+		if window.contains(project.UpdatedAt.Time) {
+			projects = append(projects, project.clone())
+		}
+	}
+	h.RUnlock()
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].UpdatedAt.After(projects[j].UpdatedAt.Time)
+	})
+	if len(projects) > maxRecentProjects {
+		projects = projects[:maxRecentProjects]
+	}
+
+	h.writeProjects(w, r, projects)
+}
+
+func (h *projectHandlers) count(w http.ResponseWriter, r *http.Request) {
+	total, err := h.total(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.cacheCollection(w, r)
+	writeJSON(w, r, http.StatusOK, map[string]int{"count": total})
+}
+
+func (h *projectHandlers) getProject(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.known.Load().mightContain(id) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
+		return
+	}
+
+	expand := r.URL.Query().Get("expand")
+	if expand != "" && expand != "related" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("expand must be related, but got %s", expand))
+		return
+	}
+
+	var related []relatedProject
+	h.RLock()
 	project, ok := h.db[id]
-	h.Unlock()
+	project = project.clone()
+	if ok && expand == "related" {
+		related = h.relatedTo(project)
+	}
+	h.RUnlock()
 
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
 		return
 	}
 
-	jsonBytes, err := json.Marshal(project)
-	fmt.Printf("json bytes: %v", jsonBytes)
+	h.lastAccessed.Store(id, time.Now())
+
+	if expand == "related" {
+		h.writeProjectWithRelated(w, r, project, related)
+		return
+	}
+	h.writeProject(w, r, project)
+}
+
+func (h *projectHandlers) stats(w http.ResponseWriter, r *http.Request, id string) {
+	h.RLock()
+	_, ok := h.db[id]
+	h.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
+		return
+	}
+
+	stats := projectStats{ID: id}
+	if t, ok := h.lastAccessed.Load(id); ok {
+		lastAccessedAt := t.(time.Time)
+		stats.LastAccessedAt = &lastAccessedAt
+	}
+
+	writeJSON(w, r, http.StatusOK, stats)
+}
+
+func (h *projectHandlers) clone(w http.ResponseWriter, r *http.Request, id string) {
+	bodyBytes, status, err := h.readBody(w, r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
+		writeError(w, status, err.Error())
 		return
 	}
 
-	w.Header().Add("content-type", "application/json")
-	w.Write(jsonBytes)
+	var body struct {
+		Name string `json:"name"`
+	}
+	if len(bytes.TrimSpace(bodyBytes)) > 0 {
+		err = json.Unmarshal(bodyBytes, &body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	createdBy := h.admin.attribute(r)
+
+	h.Lock()
+	source, ok := h.db[id]
+	if !ok {
+		h.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
+		return
+	}
+
+	name := body.Name
+	if name == "" {
+		name = "Copy of " + source.Name
+	}
+	var errs validationErrors
+	validateName(&errs, name, h.cfg)
+	if len(errs) > 0 {
+		h.Unlock()
+		writeValidationErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	copiedID := h.nextID()
+	if owner, taken := h.nameOwner(copiedID, name); taken {
+		h.Unlock()
+		writeNameConflict(w, r, name, owner)
+		return
+	}
+	if conflicts := h.issueConflicts(copiedID, source.OpenIssues); len(conflicts) > 0 {
+		h.Unlock()
+		writeIssueConflicts(w, r, conflicts)
+		return
+	}
+
+	copied := NewOpenSourceProject(CreateOpenSourceProjectReq{
+		Name:       name,
+		OpenIssues: source.OpenIssues,
+		OpenPRs:    source.OpenPRs,
+	}, copiedID, time.Now())
+	copied.Slug = h.uniqueSlug(copied.Name, "")
+	copied.CreatedBy = createdBy
+	copied.UpdatedBy = createdBy
+	h.insert(copied)
+	h.Unlock()
+
+	h.events.publish(projectEvent{Type: "created", Project: copied.clone()})
+
+	w.Header().Set("Location", h.location(copied.ID))
+	w.Header().Set("ETag", copied.ETag())
+	h.writeWriteResult(w, r, http.StatusCreated, copied.clone())
 }
 
-type adminPortal struct {
-	password string
+func (h *projectHandlers) pageIDs(w http.ResponseWriter, r *http.Request, id string, list func(OpenSourceProject) []string) {
+	limit, offset, err := parsePage(r, h.cfg.DefaultLimit, h.cfg.MaxLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.RLock()
+	project, ok := h.db[id]
+	ids := list(project)
+	total := len(ids)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	page := slices.Clone(ids[start:end])
+	h.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", id))
+		return
+	}
+
+	if page == nil {
+		page = []string{}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, r, http.StatusOK, page)
 }
 
-func newAdminPortal() *adminPortal {
-	password := os.Getenv("ADMIN_PASSWORD")
-	if password == "" {
-		panic("Required env var ADMIN PASSWORD")
+func parsePage(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, err error) {
+	limit = defaultLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer, but got %s", s)
+		}
+		limit = min(limit, maxLimit)
 	}
 
-	return &adminPortal{
-		password: password,
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer, but got %s", s)
+		}
 	}
+
+	return limit, offset, nil
+}
+
+func (h *projectHandlers) exists(w http.ResponseWriter, r *http.Request, id string) {
+	h.RLock()
+	_, ok := h.db[id]
+	h.RUnlock()
+
+	writeJSON(w, r, http.StatusOK, map[string]bool{"exists": ok})
 }
 
-func (a adminPortal) handler(w http.ResponseWriter, r *http.Request) {
-	user, pass, ok := r.BasicAuth()
-	if !ok || user != "admin" || pass != a.password {
-		w.WriteHeader(http.StatusUnauthorized)
+func writeError(w http.ResponseWriter, status int, message string) {
+	jsonBytes, _ := json.Marshal(map[string]string{"error": message})
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
+
+func writeOptions(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	if r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", allow)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// prettyJSON is the JSON_PRETTY default, set once at startup. ?pretty=true or
+// ?pretty=false overrides it per request.
+var prettyJSON bool
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	pretty := prettyJSON
+	if p, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		pretty = p
+	}
+
+	var jsonBytes []byte
+	var err error
+	if pretty {
+		jsonBytes, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		jsonBytes, err = json.Marshal(v)
+	}
+	if err != nil {
+		// Drop whatever the caller set up for the body that will never be
+		// sent, so the client can't mistake the error for it.
+		for _, header := range []string{"content-type", "ETag", "Last-Modified", "Content-Range", "Content-Disposition", "X-Total-Count", "Location"} {
+			w.Header().Del(header)
+		}
+		log.Printf("ERROR: encoding %T response for %s %s: %v", v, r.Method, r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "the response could not be encoded")
 		return
 	}
 
-	w.Write([]byte("<html><h1> Welcome to the admin dashboard </h1></html>"))
+	if w.Header().Get("content-type") == "" {
+		w.Header().Set("content-type", "application/json")
+	}
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
 }
 
-func newProjectHandlers() *projectHandlers {
-	return &projectHandlers{
-		db: map[string]OpenSourceProject{
-			"1": {
-				ID:         "1",
-				Name:       "Project 1",
-				OpenIssues: []string{"1", "2"},
-				OpenPRs:    []string{"1", "2"},
-				CreatedAt:  time.Now(),
-				UpdatedAt:  time.Now(),
-			},
-			"2": {
-				ID:         "2",
-				Name:       "Project 2",
-				OpenIssues: []string{"1", "2"},
-				OpenPRs:    []string{"1", "2"},
-				CreatedAt:  time.Now(),
-				UpdatedAt:  time.Now(),
-			},
-			"3": {
-				ID:         "3",
-				Name:       "Project 3",
-				OpenIssues: []string{"1", "2"},
-				OpenPRs:    []string{"1", "2"},
-				CreatedAt:  time.Now(),
-				UpdatedAt:  time.Now(),
-			},
-		},
+func newProjectHandlers(admin *adminPortal, cfg config) *projectHandlers {
+	h := &projectHandlers{
+		events:          newEventBroker(),
+		admin:           admin,
+		cfg:             cfg,
+		idempotencyKeys: map[string]idempotentResult{},
+		db:              map[string]OpenSourceProject{},
 	}
+	now := time.Now()
+	for _, id := range []string{"1", "2", "3"} {
+		project := NewOpenSourceProject(CreateOpenSourceProjectReq{
+			Name:       "Project " + id,
+			OpenIssues: []string{"1", "2"},
+			OpenPRs:    []string{"3", "4"},
+		}, id, now)
+		project.Slug = slugify(project.Name)
+		h.db[id] = project
+	}
+
+	h.routeTable = h.routes()
+	h.rebuildIssueIndex()
+	h.rebuildBloom()
+
+	return h
+}
+
+// underBasePath serves handler below basePath, or at the root without one.
+func underBasePath(handler http.Handler, basePath string) http.Handler {
+	if basePath == "" {
+		return handler
+	}
+	return http.StripPrefix(basePath, handler)
 }
 
 func main() {
 	fmt.Println("Start server")
 
-	openSourceHandlers := newProjectHandlers()
-	adminPortal := newAdminPortal()
+	cfg, err := loadConfig()
+	if err != nil {
+		panic(err)
+	}
+	trustedProxies = cfg.TrustedProxies
+	prettyJSON = cfg.JSONPretty
+	timeFormat = cfg.TimeFormat
+	if cfg.SelfTest {
+		if !runSelfTest(cfg) {
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.ReadOnly {
+		setReadOnly(true, "-read-only")
+	}
+
+	adminPortal := newAdminPortal(cfg)
+	openSourceHandlers := newProjectHandlers(adminPortal, cfg)
+	if err := openSourceHandlers.preload(); err != nil {
+		panic(err)
+	}
+
+	openSourceHandlers.register(http.DefaultServeMux)
+	http.HandleFunc("/opensource/events", openSourceHandlers.streamAllEvents)
+	http.HandleFunc("/opensource/ws", openSourceHandlers.streamWebSocket)
+	http.HandleFunc("/opensource/summary", openSourceHandlers.summary)
+	http.Handle("/opensource/issues/", rejectWritesWhenReadOnly(http.HandlerFunc(openSourceHandlers.moveIssue)))
+	http.HandleFunc("/admin", adminPortal.protect(adminPortal.handler))
+	http.HandleFunc("/admin/config", adminPortal.protect(adminPortal.config))
+	http.HandleFunc("/admin/password", adminPortal.protect(adminPortal.changePassword))
+	http.HandleFunc("/admin/read-only", adminPortal.protect(adminPortal.toggleReadOnly))
+	http.HandleFunc("/admin/projects/issue-usage", adminPortal.protect(openSourceHandlers.issueUsage))
+	http.HandleFunc("/admin/projects/validate", adminPortal.protect(openSourceHandlers.validateAll))
+	http.HandleFunc("/admin/projects", adminPortal.protect(openSourceHandlers.projectsByUser))
+	http.HandleFunc("/admin/reindex", adminPortal.protect(openSourceHandlers.reindex))
+	http.HandleFunc("/admin/projects/{id}/raw", adminPortal.protect(openSourceHandlers.rawProject))
+	http.HandleFunc("/admin/import", adminPortal.protect(rejectWritesWhenReadOnly(http.HandlerFunc(openSourceHandlers.importProjects)).ServeHTTP))
+	http.HandleFunc("/admin/flush", adminPortal.protect(openSourceHandlers.forceFlush))
+	http.HandleFunc("/admin/auth/check", adminPortal.checkAuth)
+	http.HandleFunc("/metrics", requestMetrics.serveMetrics)
+	http.HandleFunc("/readyz", serveReadiness)
+
+	handler := underBasePath(recordPattern(http.DefaultServeMux), cfg.BasePath)
+	handler = limitHeaders(handler, cfg.MaxHeaderCount)
+	handler = limitInFlight(handler, cfg.MaxInFlight)
+	handler = flagSlowRequests(handler, cfg.SlowRequestBudget, cfg.RouteBudgets)
+	handler = logRequests(handler, cfg.DebugBodies)
+
+	conns := newConnTracker()
+	srv := &http.Server{
+		Addr:           cfg.Addr,
+		Handler:        handler,
+		ConnState:      conns.track,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+			},
+		},
+	}
+
+	srv.RegisterOnShutdown(openSourceHandlers.events.close)
 
-	http.HandleFunc("/opensource/projects", openSourceHandlers.projects)
-	http.HandleFunc("/opensource/projects/", openSourceHandlers.getProject)
-	http.HandleFunc("/admin", adminPortal.handler)
+	if cfg.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = protocols
+	}
 
-	err := http.ListenAndServe(":8080", nil)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", srv.Addr)
 	if err != nil {
 		panic(err)
 	}
+	ready.Store(true)
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCert != "" {
+			log.Printf("serving HTTPS on %s", ln.Addr())
+			errc <- srv.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+			return
+		}
+
+		log.Printf("serving plain HTTP on %s", ln.Addr())
+		errc <- srv.Serve(ln)
+	}()
+
+	if cfg.DataFile != "" {
+		go openSourceHandlers.runSnapshots(ctx, cfg.SnapshotInterval)
+	}
+
+	select {
+	case err := <-errc:
+		panic(err)
+	case <-ctx.Done():
+	}
+	ready.Store(false)
+
+	var hooks []shutdownHook
+	if cfg.DataFile != "" {
+		hooks = append(hooks, openSourceHandlers.flushOnShutdown())
+	}
+	shutdown(srv, conns, cfg.ShutdownTimeout, hooks...)
 }
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAdminPassword = "test-password-1"
+
+// newTestHandlers returns handlers over the seeded in-memory store, configured
+// from args as the server would be, and a mux serving them. Like the
+// self-test, it has no admin users; see newTestAdmin for routes that need one.
+func newTestHandlers(t testing.TB, args ...string) (*projectHandlers, *http.ServeMux) {
+	t.Helper()
+
+	cfg := newTestConfig(t, args...)
+	h := newProjectHandlers(&adminPortal{cfg: cfg, basePath: cfg.BasePath, users: map[string]*adminUser{}}, cfg)
+	mux := http.NewServeMux()
+	h.register(mux)
+	return h, mux
+}
+
+func newTestConfig(t testing.TB, args ...string) config {
+	t.Helper()
+
+	cfg, err := parseConfig(flag.NewFlagSet(t.Name(), flag.ContinueOnError), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestAdmin returns an admin portal whose admin user has
+// testAdminPassword, and a mux serving its dashboard and credential check.
+func newTestAdmin(t testing.TB, args ...string) (*adminPortal, *http.ServeMux) {
+	t.Helper()
+
+	t.Setenv("ADMIN_PASSWORD", testAdminPassword)
+	t.Setenv("ADMIN_USERS", "")
+	admin := newAdminPortal(newTestConfig(t, args...))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin", admin.protect(admin.handler))
+	mux.HandleFunc("/admin/auth/check", admin.checkAuth)
+	return admin, mux
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func jsonRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("content-type", "application/json")
+	return req
+}
+
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{name: "getAll", method: "GET", path: "/opensource/projects", status: http.StatusOK,
+			wantBody: `"name":"Project 3"`, wantHeaders: map[string]string{"X-Total-Count": "3", "Content-Type": "application/json"}},
+		{name: "getAll short prefix", method: "GET", path: "/projects", status: http.StatusOK,
+			wantBody: `"name":"Project 1"`},
+		{name: "getProject found", method: "GET", path: "/opensource/projects/2", status: http.StatusOK,
+			wantBody: `"id":"2","name":"Project 2"`, wantHeaders: map[string]string{"ETag": `"2-1"`}},
+		{name: "getProject not found", method: "GET", path: "/opensource/projects/99", status: http.StatusNotFound,
+			wantBody: `{"error":"project 99 not found"}`},
+		{name: "getProject bad path", method: "GET", path: "/opensource/projects/1/2/3", status: http.StatusBadRequest,
+			wantBody: `"error"`},
+		{name: "post valid", method: "POST", path: "/opensource/projects", contentType: "application/json",
+			body: `{"name": "New project", "open_issues": ["7"]}`, status: http.StatusCreated,
+			wantBody: `"id":"4","name":"New project"`, wantHeaders: map[string]string{"Location": "/opensource/projects/4"}},
+		{name: "post wrong content type", method: "POST", path: "/opensource/projects", contentType: "text/plain",
+			body: `{"name": "New project"}`, status: http.StatusUnsupportedMediaType,
+			wantBody: `"error":"need content-type application-json, but got text/plain"`},
+		{name: "post malformed JSON", method: "POST", path: "/opensource/projects", contentType: "application/json",
+			body: `{"name": `, status: http.StatusBadRequest, wantBody: `"error"`},
+		{name: "post invalid", method: "POST", path: "/opensource/projects", contentType: "application/json",
+			body: `{"name": "  "}`, status: http.StatusUnprocessableEntity, wantBody: `"field":"name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mux := newTestHandlers(t)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("content-type", tt.contentType)
+			}
+			rec := serve(mux, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %s doesn't contain %s", rec.Body, tt.wantBody)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "wrong password", user: "admin", password: "wrong-password-1", status: http.StatusUnauthorized},
+		{name: "unknown user", user: "mallory", password: testAdminPassword, status: http.StatusUnauthorized},
+		{name: "valid", user: "admin", password: testAdminPassword, status: http.StatusOK},
+	}
+
+	_, mux := newTestAdmin(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, req := range []*http.Request{
+				httptest.NewRequest("GET", "/admin", nil),
+				httptest.NewRequest("POST", "/admin/auth/check", nil),
+			} {
+				if tt.user != "" {
+					req.SetBasicAuth(tt.user, tt.password)
+				}
+				rec := serve(mux, req)
+
+				if rec.Code != tt.status {
+					t.Errorf("%s %s: got status %d, want %d", req.Method, req.URL.Path, rec.Code, tt.status)
+				}
+				if tt.status == http.StatusOK && req.URL.Path == "/admin" && !strings.Contains(rec.Body.String(), "admin dashboard") {
+					t.Errorf("dashboard body %s doesn't look like the dashboard", rec.Body)
+				}
+			}
+		})
+	}
+}
+
+// TestGetProjectWhileAppending is meant for go test -race. A writer grows
+// and rewrites a stored issue list in place while the project is being read,
+// and every read must still get a whole project of its own.
+func TestGetProjectWhileAppending(t *testing.T) {
+	h, mux := newTestHandlers(t)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			h.Lock()
+			project := h.db["1"]
+			for j := range project.OpenIssues {
+				project.OpenIssues[j] = strconv.Itoa(i)
+			}
+			project.OpenIssues = append(project.OpenIssues, strconv.Itoa(i))
+			h.db["1"] = project
+			h.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			rec := serve(mux, httptest.NewRequest("GET", "/opensource/projects/1", nil))
+			var project OpenSourceProject
+			if err := json.Unmarshal(rec.Body.Bytes(), &project); rec.Code != http.StatusOK || err != nil || project.ID != "1" {
+				t.Errorf("got status %d (%v): %s", rec.Code, err, rec.Body)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// TestReadWhileIssueMoves is meant for go test -race. There is no DELETE, so
+// the removal raced against here is an issue moving between two projects:
+// each read must see it on exactly one of them.
+func TestReadWhileIssueMoves(t *testing.T) {
+	h, mux := newTestHandlers(t)
+	h.Lock()
+	for id, issues := range map[string][]string{"1": {"9"}, "2": {}} {
+		project := h.db[id]
+		project.OpenIssues = issues
+		h.db[id] = project
+	}
+	h.Unlock()
+	move := http.HandlerFunc(h.moveIssue)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		from, to := "1", "2"
+		for range 200 {
+			body := `{"from": "` + from + `", "to": "` + to + `"}`
+			if rec := serve(move, jsonRequest("POST", "/opensource/issues/9/move", body)); rec.Code != http.StatusOK {
+				t.Errorf("move: got status %d: %s", rec.Code, rec.Body)
+				return
+			}
+			from, to = to, from
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			rec := serve(mux, httptest.NewRequest("GET", "/opensource/projects?ids=1,2", nil))
+			var projects []OpenSourceProject
+			if err := json.Unmarshal(rec.Body.Bytes(), &projects); rec.Code != http.StatusOK || err != nil || len(projects) != 2 {
+				t.Errorf("get: got status %d (%v): %s", rec.Code, err, rec.Body)
+				return
+			}
+			holders := 0
+			for _, p := range projects {
+				if slices.Contains(p.OpenIssues, "9") {
+					holders++
+				}
+			}
+			if holders != 1 {
+				t.Errorf("issue 9 is listed by %d projects: %s", holders, rec.Body)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// TestListWhileCreating is meant for go test -race. Every listing taken while
+// projects are created must be a consistent snapshot: as many projects as its
+// X-Total-Count.
+func TestListWhileCreating(t *testing.T) {
+	_, mux := newTestHandlers(t)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			body := `{"name": "Created ` + strconv.Itoa(i) + `"}`
+			if rec := serve(mux, jsonRequest("POST", "/opensource/projects", body)); rec.Code != http.StatusCreated {
+				t.Errorf("post: got status %d: %s", rec.Code, rec.Body)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			rec := serve(mux, httptest.NewRequest("GET", "/opensource/projects?limit=500", nil))
+			var projects []OpenSourceProject
+			if err := json.Unmarshal(rec.Body.Bytes(), &projects); rec.Code != http.StatusOK || err != nil {
+				t.Errorf("list: got status %d (%v): %s", rec.Code, err, rec.Body)
+				return
+			}
+			if total := rec.Header().Get("X-Total-Count"); strconv.Itoa(len(projects)) != total {
+				t.Errorf("listed %d projects, but X-Total-Count is %s", len(projects), total)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestHeadCollection(t *testing.T) {
+	_, mux := newTestHandlers(t)
+	serve(mux, jsonRequest("POST", "/opensource/projects", `{"name": "Fourth"}`))
+
+	rec := serve(mux, httptest.NewRequest("HEAD", "/opensource/projects", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "4" {
+		t.Errorf("got X-Total-Count %q, want 4", got)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("got body %q, want none", rec.Body)
+	}
+}
+
+func TestCountsFollowIDs(t *testing.T) {
+	_, mux := newTestHandlers(t)
+
+	for _, tt := range []struct {
+		method, path, header, body string
+	}{
+		{method: "GET", path: "/opensource/projects?ids=1,3,1,99", header: "2"},
+		{method: "HEAD", path: "/opensource/projects?ids=1,3,1,99", header: "2"},
+		{method: "GET", path: "/opensource/projects/count?ids=1,3,1,99", body: `{"count":2}`},
+		{method: "GET", path: "/opensource/projects/count", body: `{"count":3}`},
+	} {
+		rec := serve(mux, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s %s: got status %d: %s", tt.method, tt.path, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("X-Total-Count"); tt.header != "" && got != tt.header {
+			t.Errorf("%s %s: got X-Total-Count %q, want %s", tt.method, tt.path, got, tt.header)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); tt.body != "" && got != tt.body {
+			t.Errorf("%s %s: got body %s, want %s", tt.method, tt.path, got, tt.body)
+		}
+	}
+}
+
+// TestETagChangesOnEveryUpdate patches a project twice in a row, usually within
+// one clock tick, and expects a new ETag each time.
+func TestETagChangesOnEveryUpdate(t *testing.T) {
+	_, mux := newTestHandlers(t)
+
+	seen := map[string]bool{}
+	for _, name := range []string{"", "First rename", "Second rename"} {
+		if name != "" {
+			body := `[{"id": "1", "name": "` + name + `"}]`
+			rec := serve(mux, jsonRequest("PATCH", "/opensource/projects", body))
+			if err := expectPatched(rec.Body.Bytes()); rec.Code != http.StatusOK || err != nil {
+				t.Fatalf("patch: got status %d (%v): %s", rec.Code, err, rec.Body)
+			}
+		}
+
+		etag := serve(mux, httptest.NewRequest("GET", "/opensource/projects/1", nil)).Header().Get("ETag")
+		if etag == "" || seen[etag] {
+			t.Fatalf("after renaming to %q got ETag %q, seen before: %v", name, etag, seen)
+		}
+		seen[etag] = true
+	}
+}
+
+// TestSeedsFollowDefaultRules writes to the seeded projects under default
+// flags, which reject lists sharing an id between issues and PRs.
+func TestSeedsFollowDefaultRules(t *testing.T) {
+	h, mux := newTestHandlers(t)
+
+	rec := serve(mux, jsonRequest("PATCH", "/opensource/projects", `[{"id": "1", "name": "Renamed"}]`))
+	if err := expectPatched(rec.Body.Bytes()); rec.Code != http.StatusOK || err != nil {
+		t.Errorf("rename: got status %d (%v): %s", rec.Code, err, rec.Body)
+	}
+
+	rec = serve(http.HandlerFunc(h.moveIssue), jsonRequest("POST", "/opensource/issues/1/move", `{"from": "1", "to": "2"}`))
+	if rec.Code != http.StatusOK {
+		t.Errorf("move: got status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(http.HandlerFunc(h.validateAll), httptest.NewRequest("GET", "/admin/projects/validate", nil))
+	if !strings.Contains(rec.Body.String(), `"invalid":[]`) {
+		t.Errorf("validate: got %s, want no invalid projects", rec.Body)
+	}
+}
+
+// TestCreatesRecordBothUsers checks that every way of creating a project
+// attributes it the same way: to its creator, as both creator and last writer.
+func TestCreatesRecordBothUsers(t *testing.T) {
+	admin, _ := newTestAdmin(t)
+	h := newProjectHandlers(admin, admin.cfg)
+	mux := http.NewServeMux()
+	h.register(mux)
+
+	for _, req := range []*http.Request{
+		jsonRequest("POST", "/opensource/projects", `{"name": "Posted"}`),
+		jsonRequest("POST", "/opensource/projects/1/clone", `{"name": "Cloned"}`),
+		jsonRequest("PUT", "/opensource/projects/put-1", `{"name": "Put"}`),
+	} {
+		req.SetBasicAuth("admin", testAdminPassword)
+		req.Header.Set("If-None-Match", "*")
+		rec := serve(mux, req)
+
+		var project OpenSourceProject
+		if err := json.Unmarshal(rec.Body.Bytes(), &project); rec.Code != http.StatusCreated || err != nil {
+			t.Fatalf("%s %s: got status %d (%v): %s", req.Method, req.URL.Path, rec.Code, err, rec.Body)
+		}
+		if project.CreatedBy != "admin" || project.UpdatedBy != "admin" {
+			t.Errorf("%s %s: got created_by %q and updated_by %q, want admin for both", req.Method, req.URL.Path, project.CreatedBy, project.UpdatedBy)
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		prefix string
+	}{
+		{name: "none", prefix: ""},
+		{name: "api", args: []string{"-base-path=/api"}, prefix: "/api"},
+		{name: "normalized", args: []string{"-base-path=api/v1/"}, prefix: "/api/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mux := newTestHandlers(t, tt.args...)
+			handler := underBasePath(mux, h.cfg.BasePath)
+
+			if rec := serve(handler, httptest.NewRequest("GET", tt.prefix+"/opensource/projects/1", nil)); rec.Code != http.StatusOK {
+				t.Errorf("get: got status %d, want 200", rec.Code)
+			}
+
+			rec := serve(handler, jsonRequest("POST", tt.prefix+"/opensource/projects", `{"name": "Mounted"}`))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("post: got status %d, want 201: %s", rec.Code, rec.Body)
+			}
+			if got, want := rec.Header().Get("Location"), tt.prefix+"/opensource/projects/4"; got != want {
+				t.Errorf("got Location %q, want %q", got, want)
+			}
+
+			if tt.prefix != "" {
+				if rec := serve(handler, httptest.NewRequest("GET", "/opensource/projects/1", nil)); rec.Code != http.StatusNotFound {
+					t.Errorf("get without the base path: got status %d, want 404", rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyListsMarshalAsArrays(t *testing.T) {
+	h, mux := newTestHandlers(t)
+	h.Lock()
+	h.db["1"] = OpenSourceProject{ID: "1", Name: "Stored without lists"}
+	h.Unlock()
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		serve(mux, jsonRequest("POST", "/opensource/projects", `{"name": "No lists"}`)),
+		serve(mux, jsonRequest("POST", "/opensource/projects", `{"name": "Null lists", "open_issues": null, "open_prs": null}`)),
+		serve(mux, httptest.NewRequest("GET", "/opensource/projects/1", nil)),
+	} {
+		body := rec.Body.String()
+		if strings.Contains(body, ":null") || !strings.Contains(body, `"open_issues":[]`) || !strings.Contains(body, `"open_prs":[]`) {
+			t.Errorf("empty lists aren't written as []: %s", body)
+		}
+	}
+}
+
+func TestNewOpenSourceProject(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("defaults", func(t *testing.T) {
+		p := NewOpenSourceProject(CreateOpenSourceProjectReq{Name: "  Padded  "}, "7", now)
+
+		if p.ID != "7" || p.Name != "Padded" || p.Version != 1 {
+			t.Errorf("got id %q, name %q and version %d, want 7, Padded and 1", p.ID, p.Name, p.Version)
+		}
+		if p.OpenIssues == nil || p.OpenPRs == nil || len(p.OpenIssues) != 0 || len(p.OpenPRs) != 0 {
+			t.Errorf("got issues %#v and PRs %#v, want empty non-nil slices", p.OpenIssues, p.OpenPRs)
+		}
+		if !p.CreatedAt.Equal(now) || !p.UpdatedAt.Equal(now) {
+			t.Errorf("got created %s and updated %s, want both %s", p.CreatedAt, p.UpdatedAt, now)
+		}
+	})
+
+	t.Run("lists are copied", func(t *testing.T) {
+		req := CreateOpenSourceProjectReq{Name: "Lists", OpenIssues: idList{"1", "2"}, OpenPRs: idList{"3"}}
+		p := NewOpenSourceProject(req, "8", now)
+		req.OpenIssues[0], req.OpenPRs[0] = "changed", "changed"
+
+		if !slices.Equal(p.OpenIssues, []string{"1", "2"}) || !slices.Equal(p.OpenPRs, []string{"3"}) {
+			t.Errorf("changing the request changed the project: issues %q, PRs %q", p.OpenIssues, p.OpenPRs)
+		}
+	})
+}
+
+func TestWriteJSONEncodingError(t *testing.T) {
+	for _, v := range []any{
+		map[string]any{"id": "1", "bad": make(chan int)},
+		[]float64{1, math.Inf(1)},
+	} {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("ETag", `"1-1"`)
+		rec.Header().Set("X-Total-Count", "1")
+		writeJSON(rec, httptest.NewRequest("GET", "/opensource/projects", nil), http.StatusOK, v)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%T: got status %d, want 500", v, rec.Code)
+		}
+		if got, want := rec.Body.String(), `{"error":"the response could not be encoded"}`; got != want {
+			t.Errorf("%T: got body %s, want only %s", v, got, want)
+		}
+		if rec.Header().Get("ETag") != "" || rec.Header().Get("X-Total-Count") != "" {
+			t.Errorf("%T: headers meant for the body are still set: %v", v, rec.Header())
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration
+// histogram. They match the Prometheus client defaults.
+var latencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	route  string
+	method string
+	status int
+}
+
+type latencyHistogram struct {
+	buckets [len(latencyBuckets) + 1]atomic.Uint64
+	sumNs   atomic.Int64
+}
+
+func (hist *latencyHistogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(latencyBuckets) && seconds > latencyBuckets[i] {
+		i++
+	}
+	hist.buckets[i].Add(1)
+	hist.sumNs.Add(int64(d))
+}
+
+// metrics collects request counters and latency histograms per route. Once a
+// route has been seen, recording a request only takes a read lock and a few
+// atomic adds.
+type metrics struct {
+	mu        sync.RWMutex
+	requests  map[requestKey]*atomic.Uint64
+	latencies map[string]*latencyHistogram
+}
+
+var requestMetrics = &metrics{
+	requests:  map[requestKey]*atomic.Uint64{},
+	latencies: map[string]*latencyHistogram{},
+}
+
+func (m *metrics) record(route, method string, status int, d time.Duration) {
+	key := requestKey{route: route, method: method, status: status}
+
+	m.mu.RLock()
+	counter, ok := m.requests[key]
+	hist := m.latencies[route]
+	m.mu.RUnlock()
+
+	if !ok || hist == nil {
+		m.mu.Lock()
+		if counter, ok = m.requests[key]; !ok {
+			counter = new(atomic.Uint64)
+			m.requests[key] = counter
+		}
+		if hist = m.latencies[route]; hist == nil {
+			hist = new(latencyHistogram)
+			m.latencies[route] = hist
+		}
+		m.mu.Unlock()
+	}
+
+	counter.Add(1)
+	hist.observe(d)
+}
+
+// serveMetrics writes the metrics in the Prometheus text exposition format.
+func (m *metrics) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	routes := make([]string, 0, len(m.latencies))
+	for route := range m.latencies {
+		routes = append(routes, route)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+	sort.Strings(routes)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served, by route, method and status code.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	m.mu.RLock()
+	for _, key := range keys {
+		fmt.Fprintf(w, "http_requests_total{route=%q,method=%q,code=\"%d\"} %d\n", key.route, key.method, key.status, m.requests[key].Load())
+	}
+	m.mu.RUnlock()
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Time taken to serve requests, by route.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, route := range routes {
+		m.mu.RLock()
+		hist := m.latencies[route]
+		m.mu.RUnlock()
+
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += hist.buckets[i].Load()
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{route=%q,le=%q} %d\n", route, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		cumulative += hist.buckets[len(latencyBuckets)].Load()
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, cumulative)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{route=%q} %g\n", route, time.Duration(hist.sumNs.Load()).Seconds())
+		fmt.Fprintf(w, "http_request_duration_seconds_count{route=%q} %d\n", route, cumulative)
+	}
+}
+
+// setRoute names the route a request was matched to, for metrics. The most
+// specific handler that calls it wins.
+func setRoute(w http.ResponseWriter, route string) {
+	if rec, ok := w.(*statusRecorder); ok && rec.route == "" {
+		rec.route = route
+	}
+}
+
+// recordPattern falls back to the ServeMux pattern as the route name for
+// handlers that don't set one themselves. ServeMux stores the pattern it
+// matched on the request it was given.
+func recordPattern(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		setRoute(w, r.Pattern)
+	})
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trustedProxies is set once at startup from the -trusted-proxies flag.
+var trustedProxies []netip.Prefix
+
+func isTrustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that made r. Forwarding headers
+// are only honored when the immediate peer is a trusted proxy, so clients
+// can't spoof their address by sending the headers themselves.
+func clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !isTrustedProxy(peer) {
+		return peer
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if !isTrustedProxy(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return peer
+}
+
+// requestIsHTTPS reports whether r reached us over TLS, either directly or
+// through a trusted proxy that says so in X-Forwarded-Proto.
+func requestIsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	return isTrustedProxy(peer) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status    int
+	route     string
+	requestID string
+	body      *cappedBuffer
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.body != nil {
+		rec.body.Write(p)
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer's Flush
+// and Hijack.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// debugBodyLimit caps how much of each body -debug-bodies logs.
+const debugBodyLimit = 2 << 10
+
+// cappedBuffer keeps the first debugBodyLimit bytes written to it and counts
+// the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := debugBodyLimit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	if b.total > b.Len() {
+		return fmt.Sprintf("%q... (%d bytes)", b.Bytes(), b.total)
+	}
+	return fmt.Sprintf("%q", b.Bytes())
+}
+
+// redactedHeaders are replaced when -debug-bodies logs request headers.
+var redactedHeaders = []string{"Authorization", "Cookie", "X-Csrf-Token"}
+
+func debugHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		value := strings.Join(h[name], ", ")
+		if slices.Contains(redactedHeaders, name) {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(&b, "%s: %s", name, value)
+	}
+	return b.String()
+}
+
+// requestID returns the client's X-Request-ID when it is a sensible token, so
+// ids can be followed across services, and a new random one otherwise.
+func requestID(r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	printable := !strings.ContainsFunc(id, func(c rune) bool { return c <= ' ' || c > '~' })
+	if id != "" && len(id) <= 128 && printable {
+		return id
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logRequests logs every request, tagged with its request id, and records it
+// in the metrics. With debugBodies it also logs the request headers and the
+// start of the request and response bodies, which it copies as the handler
+// reads and writes them.
+func logRequests(next http.Handler, debugBodies bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK, requestID: requestID(r)}
+		w.Header().Set("X-Request-ID", rec.requestID)
+
+		var reqBody *cappedBuffer
+		if debugBodies {
+			reqBody, rec.body = &cappedBuffer{}, &cappedBuffer{}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+
+		if rec.route == "" {
+			rec.route = "unmatched"
+		}
+		requestMetrics.record(rec.route, r.Method, rec.status, elapsed)
+
+		log.Printf("%s %s %s %s %d %s", rec.requestID, clientIP(r), r.Method, r.URL.Path, rec.status, elapsed)
+		if debugBodies {
+			log.Printf("  request headers: %s", debugHeaders(r.Header))
+			if rec.route == "/admin/password" {
+				log.Printf("  request body: [REDACTED]")
+			} else {
+				log.Printf("  request body: %s", reqBody)
+			}
+			log.Printf("  response body: %s", rec.body)
+		}
+	})
+}
+
+// limitHeaders rejects requests carrying more than max header fields. The
+// total header size is capped separately by http.Server.MaxHeaderBytes.
+func limitHeaders(next http.Handler, max int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := 0
+		for _, values := range r.Header {
+			count += len(values)
+		}
+
+		if count > max {
+			writeError(w, http.StatusRequestHeaderFieldsTooLarge, fmt.Sprintf("at most %d header fields are allowed, but got %d", max, count))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type inFlightSlotKey struct{}
+
+// limitInFlight answers 503 once max requests are already being served, using
+// a buffered channel as a semaphore. Every request takes a slot; event streams
+// and WebSocket connections stay open indefinitely, so their handlers give it
+// back with releaseInFlightSlot once the stream is established.
+func limitInFlight(next http.Handler, max int) http.Handler {
+	if max <= 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "the server is busy; try again shortly")
+			return
+		}
+
+		var once sync.Once
+		release := func() { once.Do(func() { <-slots }) }
+		defer release()
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inFlightSlotKey{}, release)))
+	})
+}
+
+// releaseInFlightSlot frees the -max-in-flight slot held by r, if any. It is
+// safe to call more than once.
+func releaseInFlightSlot(r *http.Request) {
+	if release, ok := r.Context().Value(inFlightSlotKey{}).(func()); ok {
+		release()
+	}
+}
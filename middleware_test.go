@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	const max = 3
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	handler := limitInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			releaseInFlightSlot(r)
+		}
+		entered <- struct{}{}
+		<-unblock
+	}), max)
+
+	var wg sync.WaitGroup
+	for range max {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(handler, httptest.NewRequest("GET", "/", nil))
+		}()
+		<-entered
+	}
+
+	// Headers that mark event streams and WebSockets don't get a request
+	// past a full limiter; only the handler can give a slot back.
+	for _, header := range [][2]string{{}, {"Accept", "text/event-stream"}, {"Upgrade", "websocket"}} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header[0] != "" {
+			req.Header.Set(header[0], header[1])
+		}
+		rec := serve(handler, req)
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+			t.Errorf("request with %v over the limit: got status %d and Retry-After %q, want 503 with Retry-After", header, rec.Code, rec.Header().Get("Retry-After"))
+		}
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	// Finished requests gave their slots back, and a stream that releases its
+	// slot early doesn't hold one while it stays open.
+	unblock = make(chan struct{})
+	for range max + 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := serve(handler, httptest.NewRequest("GET", "/stream", nil)); rec.Code == http.StatusServiceUnavailable {
+				t.Error("a stream was turned away although the others released their slots")
+				entered <- struct{}{}
+			}
+		}()
+		<-entered
+	}
+	close(unblock)
+	wg.Wait()
+}
+
+func BenchmarkLimitInFlight(b *testing.B) {
+	const max = 4
+	var active, peak atomic.Int64
+	handler := limitInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		active.Add(-1)
+	}), max)
+
+	var rejected atomic.Int64
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if serve(handler, httptest.NewRequest("GET", "/", nil)).Code == http.StatusServiceUnavailable {
+				rejected.Add(1)
+			}
+		}
+	})
+
+	if peak.Load() > max {
+		b.Errorf("%d requests were served at once, more than the limit of %d", peak.Load(), max)
+	}
+	b.ReportMetric(float64(rejected.Load())/float64(b.N), "rejected/op")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type moveIssueReq struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// moveIssue serves POST /opensource/issues/{issue}/move, taking an open issue
+// off one project and adding it to another under a single lock, so the issue
+// is never listed by both or by neither.
+func (h *projectHandlers) moveIssue(w http.ResponseWriter, r *http.Request) {
+	issue, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/opensource/issues/"), "/move")
+	if !ok || issue == "" || strings.Contains(issue, "/") {
+		writeError(w, http.StatusNotFound, "expected /opensource/issues/{issue}/move")
+		return
+	}
+	if r.Method != "POST" {
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+
+	bodyBytes, status, err := h.readBody(w, r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	if ct := r.Header.Get("content-type"); ct != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("need content-type application-json, but got %s", ct))
+		return
+	}
+
+	var body moveIssueReq
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.From == "" || body.To == "" {
+		writeError(w, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	if body.From == body.To {
+		writeError(w, http.StatusBadRequest, "from and to must be different projects")
+		return
+	}
+
+	updatedBy := h.admin.attribute(r)
+
+	h.Lock()
+	from, fromOK := h.db[body.From]
+	to, toOK := h.db[body.To]
+	switch {
+	case !fromOK:
+		h.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", body.From))
+		return
+	case !toOK:
+		h.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("project %s not found", body.To))
+		return
+	case !slices.Contains(from.OpenIssues, issue):
+		h.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("issue %s is not open on project %s", issue, body.From))
+		return
+	}
+
+	now := time.Now()
+	movedFrom, movedTo := from.clone(), to.clone()
+	movedFrom.OpenIssues = slices.DeleteFunc(movedFrom.OpenIssues, func(id string) bool { return id == issue })
+	if !slices.Contains(movedTo.OpenIssues, issue) {
+		movedTo.OpenIssues = append(movedTo.OpenIssues, issue)
+	}
+
+	check := CreateOpenSourceProjectReq{Name: movedTo.Name, OpenIssues: movedTo.OpenIssues, OpenPRs: movedTo.OpenPRs}
+	if errs := check.Validate(h.cfg); len(errs) > 0 {
+		h.Unlock()
+		writeValidationErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	for _, p := range []*OpenSourceProject{&movedFrom, &movedTo} {
+		p.Version++
+		p.UpdatedAt = timestamp{now}
+		p.UpdatedBy = updatedBy
+	}
+	h.db[movedFrom.ID] = movedFrom
+	h.db[movedTo.ID] = movedTo
+	h.reindexIssues(movedFrom.ID, from.OpenIssues, movedFrom.OpenIssues)
+	h.reindexIssues(movedTo.ID, to.OpenIssues, movedTo.OpenIssues)
+	h.markDirty()
+	h.Unlock()
+
+	movedFrom, movedTo = movedFrom.clone(), movedTo.clone()
+	h.events.publish(projectEvent{Type: "updated", Project: movedFrom})
+	h.events.publish(projectEvent{Type: "updated", Project: movedTo})
+
+	writeJSON(w, r, http.StatusOK, map[string]OpenSourceProject{"from": movedFrom, "to": movedTo})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// parseCreateReq decodes and validates a create body without touching the
+// store or the response, so it can be exercised on its own. An error means
+// the body is malformed (400); validation errors mean it parsed but breaks a
+// rule (422).
+func parseCreateReq(data []byte, cfg config) (CreateOpenSourceProjectReq, validationErrors, error) {
+	var req CreateOpenSourceProjectReq
+	if err := checkJSONShape(data, cfg.MaxJSONDepth, cfg.MaxListItems); err != nil {
+		return req, nil, err
+	}
+	if err := createProjectReqSchema.validateBytes(data); err != nil {
+		return req, nil, err
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, nil, err
+	}
+	return req, req.Validate(cfg), nil
+}
+
+// parsePatchReqs decodes a batch patch body. Items are validated later,
+// against the projects they apply to.
+func parsePatchReqs(data []byte, cfg config) ([]PatchOpenSourceProjectReq, error) {
+	var reqs []PatchOpenSourceProjectReq
+	if err := checkJSONShape(data, cfg.MaxJSONDepth, max(cfg.MaxListItems, maxBatchIDs)); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reqs); err != nil {
+		return nil, err
+	}
+	if len(reqs) > maxBatchIDs {
+		return nil, fmt.Errorf("at most %d projects can be patched at once, but got %d", maxBatchIDs, len(reqs))
+	}
+	return reqs, nil
+}
+
+// checkJSONShape walks data token by token and rejects it once it nests
+// deeper than maxDepth or an array grows past maxItems, so a hostile body
+// is turned away before it is decoded into anything.
+func checkJSONShape(data []byte, maxDepth, maxItems int) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// counts holds the number of items seen so far in each open array, or -1
+	// for an open object.
+	var counts []int
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			counts = counts[:len(counts)-1]
+			continue
+		}
+
+		if n := len(counts); n > 0 && counts[n-1] >= 0 {
+			counts[n-1]++
+			if counts[n-1] > maxItems {
+				return fmt.Errorf("JSON arrays can have at most %d items", maxItems)
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			counts = append(counts, -1)
+		case json.Delim('['):
+			counts = append(counts, 0)
+		}
+		if len(counts) > maxDepth {
+			return fmt.Errorf("JSON can be nested at most %d levels deep", maxDepth)
+		}
+	}
+}
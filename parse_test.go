@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fuzzHandler sends body to the handlers and fails unless the response has
+// one of the statuses allowed and, when it has a body, is JSON.
+func fuzzHandler(t *testing.T, mux http.Handler, method, body string, allowed []int) {
+	req := httptest.NewRequest(method, "/opensource/projects", strings.NewReader(body))
+	req.Header.Set("content-type", "application/json")
+	rec := serve(mux, req)
+
+	if !slices.Contains(allowed, rec.Code) {
+		t.Fatalf("%s %q: got status %d, want one of %v: %s", method, body, rec.Code, allowed, rec.Body)
+	}
+	if rec.Body.Len() > 0 && !json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("%s %q: response body isn't JSON: %s", method, body, rec.Body)
+	}
+}
+
+var fuzzSeeds = []string{
+	`{"name": "Fuzzed", "open_issues": ["1", 2], "open_prs": []}`,
+	`[{"id": "1", "name": "Renamed", "open_issues": []}, {"id": "2", "open_prs": ["3"]}]`,
+	`{"name": ""}`,
+	`[{"id": "", "name": "x"}]`,
+	`{"name": "x", "open_issues": [1.5, -3, 1e40, null]}`,
+	`[[[[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]]]]`,
+	`{"name": "\u0000", "extra": {"a": [true]}}`,
+	`{`,
+	`null`,
+	``,
+}
+
+func FuzzPost(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	_, mux := newTestHandlers(f, "-unique-names", "-unique-issues")
+
+	allowed := []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity}
+	f.Fuzz(func(t *testing.T, body string) {
+		fuzzHandler(t, mux, "POST", body, allowed)
+	})
+}
+
+func FuzzPatch(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	_, mux := newTestHandlers(f, "-unique-names", "-unique-issues")
+
+	allowed := []int{http.StatusOK, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity}
+	f.Fuzz(func(t *testing.T, body string) {
+		fuzzHandler(t, mux, "PATCH", body, allowed)
+	})
+}
+
+func TestDeeplyNestedBody(t *testing.T) {
+	nested := func(depth int) string {
+		return `{"name": "Nested", "open_issues": ` + strings.Repeat("[", depth) + strings.Repeat("]", depth) + `}`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+	}{
+		{name: "100000 levels", body: nested(100000), status: http.StatusBadRequest, message: "nested at most 16 levels deep"},
+		{name: "one level too deep", body: nested(16), status: http.StatusBadRequest, message: "nested at most 16 levels deep"},
+		{name: "nested objects", body: `{"name": "Nested", "x": ` + strings.Repeat(`{"a": `, 20) + `1` + strings.Repeat("}", 20) + `}`, status: http.StatusBadRequest, message: "nested at most 16 levels deep"},
+		{name: "too many ids", body: `{"name": "Long", "open_issues": [` + strings.Repeat(`"1", `, 1000) + `"1"]}`, status: http.StatusBadRequest, message: "at most 1000 items"},
+		{name: "within the limits", body: `{"name": "Fine", "open_issues": [` + strings.Repeat(`"1", `, 999) + `"1"]}`, status: http.StatusCreated},
+	}
+
+	_, mux := newTestHandlers(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux, jsonRequest("POST", "/opensource/projects", tt.body))
+
+			if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.message) {
+				t.Errorf("got status %d and body %.200s, want %d mentioning %q", rec.Code, rec.Body, tt.status, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"unicode"
+)
+
+const (
+	minAdminPasswordLength = 12
+
+	// pbkdf2Iterations makes every guess at a stolen hash cost a noticeable
+	// fraction of a second, following OWASP's advice for PBKDF2-HMAC-SHA256.
+	pbkdf2Iterations = 600_000
+)
+
+// credential is a PBKDF2-HMAC-SHA256 hash of a password, so the password
+// itself is never kept in memory or written to disk after startup.
+type credential struct {
+	Salt       []byte `json:"salt"`
+	Hash       []byte `json:"hash"`
+	Iterations int    `json:"iterations"`
+}
+
+func newCredential(password string) (*credential, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &credential{Salt: salt, Hash: hashPassword(salt, password, pbkdf2Iterations), Iterations: pbkdf2Iterations}, nil
+}
+
+func hashPassword(salt []byte, password string, iterations int) []byte {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+func (c *credential) matches(password string) bool {
+	return subtle.ConstantTimeCompare(c.Hash, hashPassword(c.Salt, password, c.Iterations)) == 1
+}
+
+// credentialFile is where a rotated password is kept when the store is file
+// backed. It is empty for in-memory stores.
+func (a *adminPortal) credentialFile() string {
+	if a.cfg.DataFile == "" {
+		return ""
+	}
+	return a.cfg.DataFile + ".admin"
+}
+
+// loadCredential returns the credential saved in path, or nil if there is
+// none, in which case ADMIN_PASSWORD is used. Files from before PBKDF2 hold a
+// hash that is too cheap to brute-force, so they are ignored.
+func loadCredential(path string) (*credential, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cred credential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, err
+	}
+	if cred.Iterations <= 0 {
+		log.Printf("WARNING: %s holds an admin password hash in the old single SHA-256 format; ignoring it and using ADMIN_PASSWORD. Rotate the password to replace it", path)
+		return nil, nil
+	}
+	return &cred, nil
+}
+
+func weakPasswordReason(password string) string {
+	if len(password) < minAdminPasswordLength {
+		return "new password must be at least 12 characters"
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return "new password must contain both letters and digits"
+	}
+	return ""
+}
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// changePassword rotates the admin password. The new credential is saved
+// before it replaces the old one, so a failed write leaves the old password
+// in effect. Rotations are serialized so the file and memory always agree.
+// Users from ADMIN_USERS are managed there and can't be rotated here.
+func (a *adminPortal) changePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+
+	var body changePasswordReq
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if adminUserFrom(r) != "admin" {
+		writeError(w, http.StatusForbidden, "only the admin user's password can be rotated; other users are managed through ADMIN_USERS")
+		return
+	}
+	admin := a.users["admin"]
+
+	a.passwordMu.Lock()
+	defer a.passwordMu.Unlock()
+
+	current := admin.credential.Load()
+	if !current.matches(body.CurrentPassword) {
+		writeError(w, http.StatusForbidden, "current password is incorrect")
+		return
+	}
+	if reason := weakPasswordReason(body.NewPassword); reason != "" {
+		writeError(w, http.StatusBadRequest, reason)
+		return
+	}
+	if body.NewPassword == body.CurrentPassword {
+		writeError(w, http.StatusBadRequest, "new password must differ from the current one")
+		return
+	}
+
+	next, err := newCredential(body.NewPassword)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if path := a.credentialFile(); path != "" {
+		data, err := json.Marshal(next)
+		if err == nil {
+			err = writeFileAtomic(path, data)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	admin.credential.Store(next)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PatchOpenSourceProjectReq is a partial update of the project with the given
+// id. Fields that are left out keep their current value, while an explicit
+// empty list clears it.
+type PatchOpenSourceProjectReq struct {
+	ID         string  `json:"id"`
+	Name       *string `json:"name"`
+	OpenIssues *idList `json:"open_issues"`
+	OpenPRs    *idList `json:"open_prs"`
+}
+
+type patchResult struct {
+	ID      string             `json:"id"`
+	OK      bool               `json:"ok"`
+	Error   string             `json:"error,omitempty"`
+	Errors  validationErrors   `json:"errors,omitempty"`
+	Project *OpenSourceProject `json:"project,omitempty"`
+}
+
+// apply returns p with req's fields merged in.
+func (req PatchOpenSourceProjectReq) apply(p OpenSourceProject) OpenSourceProject {
+	if req.Name != nil {
+		p.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.OpenIssues != nil {
+		p.OpenIssues = nonNil(*req.OpenIssues)
+	}
+	if req.OpenPRs != nil {
+		p.OpenPRs = nonNil(*req.OpenPRs)
+	}
+	return p
+}
+
+// patchMany applies a batch of partial updates under a single lock. By default
+// each item succeeds or fails on its own; with ?atomic=true every item is
+// validated first and nothing is applied unless all of them are valid, with
+// 422 reported otherwise.
+func (h *projectHandlers) patchMany(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, status, err := h.readBody(w, r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	ct := r.Header.Get("content-type")
+	if ct != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("need content-type application-json, but got %s", ct))
+		return
+	}
+
+	body, err := parsePatchReqs(bodyBytes, h.cfg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updatedBy := h.admin.attribute(r)
+	atomic := r.URL.Query().Get("atomic") == "true"
+	results := make([]patchResult, len(body))
+	updated := make([]OpenSourceProject, len(body))
+	failed := false
+
+	h.Lock()
+
+	// Items are checked against a working copy so that later items in the
+	// batch see the effect of earlier ones, as they would when applied.
+	working := map[string]OpenSourceProject{}
+	claimed := map[string]string{}
+	lookup := func(id string) (OpenSourceProject, bool) {
+		if p, ok := working[id]; ok {
+			return p, true
+		}
+		p, ok := h.db[id]
+		return p, ok
+	}
+
+	// issueOwner finds another project that still lists issue in the working
+	// copy, so an issue an earlier item took off its owner is free to claim.
+	issueOwner := func(id, issue string) (string, bool) {
+		if !h.cfg.UniqueIssues {
+			return "", false
+		}
+		for _, owner := range []string{claimed[issue], h.issueOwners[issue]} {
+			if owner == "" || owner == id {
+				continue
+			}
+			if p, ok := lookup(owner); ok && slices.Contains(p.OpenIssues, issue) {
+				return owner, true
+			}
+		}
+		return "", false
+	}
+
+	for i, req := range body {
+		results[i].ID = req.ID
+
+		current, ok := lookup(req.ID)
+		if !ok {
+			results[i].Error = "not found"
+			failed = true
+			continue
+		}
+
+		next := req.apply(current)
+		check := CreateOpenSourceProjectReq{Name: next.Name, OpenIssues: next.OpenIssues, OpenPRs: next.OpenPRs}
+		if errs := check.Validate(h.cfg); len(errs) > 0 {
+			results[i].Errors = errs
+			failed = true
+			continue
+		}
+
+		if h.cfg.UniqueNames && req.Name != nil {
+			for otherID := range h.db {
+				other, _ := lookup(otherID)
+				if otherID != next.ID && strings.EqualFold(strings.TrimSpace(other.Name), strings.TrimSpace(next.Name)) {
+					results[i].Error = describeNameConflict(next.Name, otherID)
+					failed = true
+					break
+				}
+			}
+			if results[i].Error != "" {
+				continue
+			}
+		}
+
+		var added []string
+		for _, issue := range next.OpenIssues {
+			if !slices.Contains(current.OpenIssues, issue) {
+				added = append(added, issue)
+			}
+		}
+		conflicts := map[string]string{}
+		for _, issue := range added {
+			if owner, ok := issueOwner(next.ID, issue); ok {
+				conflicts[issue] = owner
+			}
+		}
+		if len(conflicts) > 0 {
+			results[i].Error = describeIssueConflicts(conflicts)
+			failed = true
+			continue
+		}
+
+		next.Version++
+		next.UpdatedAt = timestamp{time.Now()}
+		next.UpdatedBy = updatedBy
+		if h.cfg.UniqueIssues {
+			for _, issue := range added {
+				claimed[issue] = next.ID
+			}
+		}
+
+		working[next.ID] = next
+		updated[i] = next
+		results[i].OK = true
+	}
+
+	if atomic && failed {
+		h.Unlock()
+
+		for i := range results {
+			if results[i].OK {
+				results[i].OK = false
+				results[i].Error = "not applied because another item in the atomic batch failed"
+			}
+		}
+		writeJSON(w, r, http.StatusUnprocessableEntity, results)
+		return
+	}
+
+	for i := range results {
+		if !results[i].OK {
+			continue
+		}
+
+		project := updated[i]
+		previous := h.db[project.ID]
+		if project.Name != previous.Name {
+			project.Slug = h.uniqueSlug(project.Name, project.ID)
+		}
+		h.db[project.ID] = project
+		h.reindexIssues(project.ID, previous.OpenIssues, project.OpenIssues)
+
+		project = project.clone()
+		results[i].Project = &project
+	}
+	if len(working) > 0 {
+		h.markDirty()
+	}
+	h.Unlock()
+
+	for _, result := range results {
+		if result.OK {
+			h.events.publish(projectEvent{Type: "updated", Project: *result.Project})
+		}
+	}
+
+	if preferMinimal(r) {
+		if !failed {
+			w.Header().Set("Preference-Applied", "return=minimal")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for i := range results {
+			results[i].Project = nil
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, results)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestPatchOmittedVersusEmptyLists(t *testing.T) {
+	tests := []struct {
+		name       string
+		fields     string
+		wantIssues []string
+		wantPRs    []string
+	}{
+		{name: "both omitted", fields: `"name": "Renamed"`, wantIssues: []string{"1"}, wantPRs: []string{"2"}},
+		{name: "null is omitted", fields: `"open_issues": null, "open_prs": null`, wantIssues: []string{"1"}, wantPRs: []string{"2"}},
+		{name: "issues emptied", fields: `"open_issues": []`, wantIssues: []string{}, wantPRs: []string{"2"}},
+		{name: "PRs emptied", fields: `"open_prs": []`, wantIssues: []string{"1"}, wantPRs: []string{}},
+		{name: "both emptied", fields: `"open_issues": [], "open_prs": []`, wantIssues: []string{}, wantPRs: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mux := newTestHandlers(t)
+			h.Lock()
+			project := h.db["1"]
+			project.OpenIssues, project.OpenPRs = []string{"1"}, []string{"2"}
+			h.db["1"] = project
+			h.Unlock()
+
+			rec := serve(mux, jsonRequest("PATCH", "/opensource/projects", `[{"id": "1", `+tt.fields+`}]`))
+			var results []patchResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &results); rec.Code != http.StatusOK || err != nil || len(results) != 1 || !results[0].OK {
+				t.Fatalf("got status %d (%v): %s", rec.Code, err, rec.Body)
+			}
+
+			got := results[0].Project
+			if !slices.Equal(got.OpenIssues, tt.wantIssues) || !slices.Equal(got.OpenPRs, tt.wantPRs) {
+				t.Errorf("got issues %q and PRs %q, want %q and %q", got.OpenIssues, got.OpenPRs, tt.wantIssues, tt.wantPRs)
+			}
+		})
+	}
+}
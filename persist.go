@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// storedProject is how a project is written to the data file. Its times are
+// plain time.Time, so they are always kept as RFC 3339 with nanoseconds
+// whatever -time-format says about API output.
+type storedProject struct {
+	OpenSourceProject
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func stored(p OpenSourceProject) storedProject {
+	return storedProject{OpenSourceProject: p, CreatedAt: p.CreatedAt.Time, UpdatedAt: p.UpdatedAt.Time}
+}
+
+// loadFile replaces the store with the projects saved in path. A missing file
+// keeps the seeded store. A file that can't be parsed is moved aside to
+// <path>.corrupt.<timestamp> so the service still starts and the data can be
+// recovered by hand. It returns where the store's projects came from, for the
+// startup log.
+func (h *projectHandlers) loadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "seed data, as " + path + " does not exist yet", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var projects []OpenSourceProject
+	if err := json.Unmarshal(data, &projects); err != nil {
+		backup := fmt.Sprintf("%s.corrupt.%s", path, time.Now().UTC().Format("20060102T150405Z"))
+		if renameErr := os.Rename(path, backup); renameErr != nil {
+			return "", fmt.Errorf("data file %s is corrupt (%v) and could not be backed up: %w", path, err, renameErr)
+		}
+
+		log.Printf("WARNING: data file %s is corrupt (%v); moved it to %s and starting with the seed data", path, err, backup)
+		return "seed data, as " + path + " was corrupt", nil
+	}
+
+	db := make(map[string]OpenSourceProject, len(projects))
+	for _, project := range projects {
+		db[project.ID] = project
+	}
+
+	h.Lock()
+	h.db = db
+	for id, project := range db {
+		if project.Slug == "" {
+			project.Slug = h.uniqueSlug(project.Name, id)
+			db[id] = project
+		}
+	}
+	h.rebuildIssueIndex()
+	h.rebuildBloom()
+	h.Unlock()
+
+	return path, nil
+}
+
+// flushOnShutdown is the shutdown hook that saves whatever changed since the
+// last snapshot, including writes from requests that were drained.
+func (h *projectHandlers) flushOnShutdown() shutdownHook {
+	return shutdownHook{
+		name: "saving projects to " + h.cfg.DataFile,
+		run: func() error {
+			if !h.dirty.Load() {
+				return nil
+			}
+			_, err := h.flush()
+			return err
+		},
+	}
+}
+
+// markDirty records that the store changed since the last snapshot. The
+// change will be written by the next periodic flush.
+func (h *projectHandlers) markDirty() {
+	if h.cfg.DataFile != "" {
+		h.dirty.Store(true)
+	}
+}
+
+// runSnapshots flushes the store to disk at most once per interval, and only
+// when something changed, until ctx is done.
+func (h *projectHandlers) runSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !h.dirty.Load() {
+				continue
+			}
+			if _, err := h.flush(); err != nil {
+				log.Printf("failed to save projects to %s: %v", h.cfg.DataFile, err)
+			}
+		}
+	}
+}
+
+// flush writes a snapshot of the whole store to the data file and returns the
+// number of bytes written.
+func (h *projectHandlers) flush() (int, error) {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+
+	h.dirty.Store(false)
+
+	h.RLock()
+	projects := make([]OpenSourceProject, 0, len(h.db))
+	for _, project := range h.db {
+		projects = append(projects, project)
+	}
+	sortedByID(projects)
+	records := make([]storedProject, len(projects))
+	for i, project := range projects {
+		records[i] = stored(project)
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	h.RUnlock()
+
+	if err == nil {
+		err = writeFileAtomic(h.cfg.DataFile, data)
+	}
+	if err != nil {
+		h.dirty.Store(true)
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// forceFlush writes a snapshot now rather than at the next interval, e.g. just
+// before a backup is taken.
+func (h *projectHandlers) forceFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+	if h.cfg.DataFile == "" {
+		writeError(w, http.StatusConflict, "persistence is disabled: the store is in memory only")
+		return
+	}
+
+	start := time.Now()
+	n, err := h.flush()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"bytes":    n,
+		"duration": time.Since(start).String(),
+	})
+}
+
+// rawProject returns a project encoded the way flush writes it to the data
+// file, for comparing what is stored with what the API serves.
+func (h *projectHandlers) rawProject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	h.RLock()
+	project, ok := h.db[r.PathValue("id")]
+	var data []byte
+	var err error
+	if ok {
+		data, err = json.MarshalIndent(stored(project), "", "  ")
+	}
+	h.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Write(data)
+}
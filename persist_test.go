@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCorruptDataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	const corrupt = `[{"id": "1", "name": `
+	if err := os.WriteFile(path, []byte(corrupt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATA_FILE", path)
+	h, _ := newTestHandlers(t)
+
+	source, err := h.loadFile(path)
+	if err != nil {
+		t.Fatalf("loading a corrupt file failed instead of falling back: %v", err)
+	}
+	if !strings.Contains(source, "seed data") {
+		t.Errorf("got source %q, want the seed data", source)
+	}
+	if len(h.db) != 3 {
+		t.Errorf("got %d projects, want the 3 seeded ones", len(h.db))
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the corrupt file is still at %s: %v", path, err)
+	}
+	backups, _ := filepath.Glob(path + ".corrupt.*")
+	if len(backups) != 1 {
+		t.Fatalf("got backups %v, want one", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != corrupt {
+		t.Errorf("backup holds %q, want the original %q", data, corrupt)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// preferMinimal reports whether the request carries the RFC 7240 preference
+// return=minimal. return=representation, or no preference, means the full
+// body.
+func preferMinimal(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(pref, ";")
+			name, value, _ := strings.Cut(strings.TrimSpace(token), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "return") {
+				return strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), "minimal")
+			}
+		}
+	}
+	return false
+}
+
+// writeWriteResult answers a create or update. Clients preferring
+// return=minimal get 204 with only the headers already set, such as
+// Location; everyone else gets the project.
+func (h *projectHandlers) writeWriteResult(w http.ResponseWriter, r *http.Request, status int, project OpenSourceProject) {
+	if preferMinimal(r) {
+		w.Header().Set("Preference-Applied", "return=minimal")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.writeProjectBody(w, r, status, project)
+}
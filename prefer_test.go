@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPreferReturn(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefer  string
+		minimal bool
+	}{
+		{name: "no preference"},
+		{name: "representation", prefer: "return=representation"},
+		{name: "minimal", prefer: "return=minimal", minimal: true},
+		{name: "minimal among others", prefer: `respond-async, return="minimal"; foo=bar`, minimal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mux := newTestHandlers(t)
+
+			for _, write := range []struct {
+				method, body string
+				status       int
+			}{
+				{"POST", `{"name": "Preferred"}`, http.StatusCreated},
+				{"PATCH", `[{"id": "1", "name": "Preferred"}]`, http.StatusOK},
+			} {
+				req := jsonRequest(write.method, "/opensource/projects", write.body)
+				if tt.prefer != "" {
+					req.Header.Set("Prefer", tt.prefer)
+				}
+				rec := serve(mux, req)
+
+				if !tt.minimal {
+					if rec.Code != write.status || !strings.Contains(rec.Body.String(), `"id":`) {
+						t.Errorf("%s: got status %d and body %s, want %d with the project", write.method, rec.Code, rec.Body, write.status)
+					}
+					continue
+				}
+				if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+					t.Errorf("%s: got status %d and body %q, want 204 and no body", write.method, rec.Code, rec.Body)
+				}
+				if got := rec.Header().Get("Preference-Applied"); got != "return=minimal" {
+					t.Errorf("%s: got Preference-Applied %q, want return=minimal", write.method, got)
+				}
+				if write.method == "POST" && rec.Header().Get("Location") != "/opensource/projects/4" {
+					t.Errorf("POST: got Location %q, want /opensource/projects/4", rec.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// clientIDPattern is what ids chosen by clients with PUT or import must look
+// like.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// clientIDProblem says what is wrong with an id a client chose, or returns ""
+// if it is fine. Ids equal to the first segment of a literal route, such as
+// "count", would be shadowed by that route, so they are reserved.
+func (h *projectHandlers) clientIDProblem(id string) string {
+	if !clientIDPattern.MatchString(id) {
+		return "must be 1 to 64 letters, digits, hyphens or underscores"
+	}
+	for _, rt := range h.routeTable {
+		if first, _, _ := strings.Cut(rt.path, "/"); first == id {
+			return fmt.Sprintf("is reserved by the route /opensource/projects/%s", rt.path)
+		}
+	}
+	return ""
+}
+
+// put creates a project under an id the client chose. It only creates, so it
+// requires If-None-Match: * and answers 412 when the id is taken, which makes
+// provisioning with deterministic ids safe to retry.
+func (h *projectHandlers) put(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("If-None-Match") != "*" {
+		writeError(w, http.StatusPreconditionRequired, "PUT only creates projects and needs If-None-Match: *")
+		return
+	}
+
+	if problem := h.clientIDProblem(id); problem != "" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("id %q %s", id, problem))
+		return
+	}
+
+	bodyBytes, status, err := h.readBody(w, r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	ct := r.Header.Get("content-type")
+	if ct != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("need content-type application-json, but got %s", ct))
+		return
+	}
+
+	body, errs, err := parseCreateReq(bodyBytes, h.cfg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	createdBy := h.admin.attribute(r)
+
+	h.Lock()
+	if _, ok := h.db[id]; ok {
+		h.Unlock()
+		writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("project %s already exists", id))
+		return
+	}
+
+	if owner, taken := h.nameOwner(id, body.Name); taken {
+		h.Unlock()
+		writeNameConflict(w, r, body.Name, owner)
+		return
+	}
+	if conflicts := h.issueConflicts(id, body.OpenIssues); len(conflicts) > 0 {
+		h.Unlock()
+		writeIssueConflicts(w, r, conflicts)
+		return
+	}
+
+	project := NewOpenSourceProject(body, id, time.Now())
+	project.Slug = h.uniqueSlug(project.Name, "")
+	project.CreatedBy = createdBy
+	project.UpdatedBy = createdBy
+	h.insert(project)
+	h.Unlock()
+
+	h.events.publish(projectEvent{Type: "created", Project: project.clone()})
+
+	w.Header().Set("Location", h.location(project.ID))
+	w.Header().Set("ETag", project.ETag())
+	h.writeWriteResult(w, r, http.StatusCreated, project)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseItemsRange reads a Range header in the items unit, such as items=0-49
+// or items=-10 for the last ten, against a list of total items. It reports
+// ok=false when the header should be ignored: absent, in another unit, asking
+// for several ranges or malformed, all of which get the full response.
+// Asking only for items past the end is unsatisfiable.
+func parseItemsRange(header string, total int) (start, end int, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(header, "items=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+
+	if first == "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || total == 0 {
+			return 0, 0, true, false
+		}
+		return max(total-n, 0), total - 1, true, true
+	}
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = total - 1
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return 0, 0, false, false
+		}
+	}
+
+	if start >= total {
+		return 0, 0, true, false
+	}
+	return start, min(end, total-1), true, true
+}
+
+// writeIssueRange answers a Range request for part of a project's open
+// issues with 206, or 416 when none of the requested issues exist. It
+// reports whether it handled the request.
+func (h *projectHandlers) writeIssueRange(w http.ResponseWriter, r *http.Request, project OpenSourceProject) bool {
+	total := len(project.OpenIssues)
+	start, end, ok, satisfiable := parseItemsRange(r.Header.Get("Range"), total)
+	if !ok {
+		return false
+	}
+
+	if !satisfiable {
+		w.Header().Set("Content-Range", fmt.Sprintf("items */%d", total))
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, fmt.Sprintf("the project has %d open issues", total))
+		return true
+	}
+
+	project.OpenIssues = project.OpenIssues[start : end+1]
+	w.Header().Set("Content-Range", fmt.Sprintf("items %d-%d/%d", start, end, total))
+	h.writeProjectBody(w, r, http.StatusPartialContent, project)
+	return true
+}
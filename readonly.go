@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// readOnly is set at startup from -read-only and can be flipped at runtime
+// through /admin/read-only.
+var readOnly atomic.Bool
+
+func setReadOnly(on bool, reason string) {
+	if readOnly.Swap(on) == on {
+		return
+	}
+
+	if on {
+		log.Printf("read-only mode enabled (%s)", reason)
+	} else {
+		log.Printf("read-only mode disabled (%s)", reason)
+	}
+}
+
+// rejectWritesWhenReadOnly answers mutating requests with 503 while the
+// server is in read-only mode. Reads keep working.
+func rejectWritesWhenReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST", "PUT", "PATCH", "DELETE":
+			if readOnly.Load() {
+				w.Header().Set("Retry-After", "60")
+				writeError(w, http.StatusServiceUnavailable, "the server is in read-only mode for maintenance; try again later")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type readOnlyState struct {
+	ReadOnly *bool `json:"read_only"`
+}
+
+// toggleReadOnly reports read-only mode on GET and switches it on POST with
+// {"read_only": true|false}.
+func (a *adminPortal) toggleReadOnly(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+	case "POST":
+		var body readOnlyState
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.ReadOnly == nil {
+			writeError(w, http.StatusBadRequest, "read_only is required")
+			return
+		}
+
+		setReadOnly(*body.ReadOnly, "changed by "+adminUserFrom(r)+" from "+clientIP(r))
+	default:
+		methodNotAllowed(w, r, "GET, HEAD, POST")
+		return
+	}
+
+	on := readOnly.Load()
+	writeJSON(w, r, http.StatusOK, readOnlyState{ReadOnly: &on})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ready is set once the store has been preloaded and the listening socket is
+// bound, and cleared again when shutdown starts so load balancers stop sending
+// traffic.
+var ready atomic.Bool
+
+// preload loads the store and builds its indexes before the server accepts
+// connections, so the first requests don't pay for it.
+func (h *projectHandlers) preload() error {
+	start := time.Now()
+
+	source := "seed data"
+	if h.cfg.DataFile != "" {
+		var err error
+		if source, err = h.loadFile(h.cfg.DataFile); err != nil {
+			return err
+		}
+	}
+
+	h.RLock()
+	count := len(h.db)
+	h.RUnlock()
+
+	log.Printf("loaded %d projects from %s in %s", count, source, time.Since(start))
+	return nil
+}
+
+func serveReadiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready.Load() {
+		writeJSON(w, r, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "ready"})
+}
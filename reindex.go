@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type reindexResult struct {
+	Duration      string            `json:"duration"`
+	Projects      int               `json:"projects"`
+	SlugsChanged  map[string]string `json:"slugs_changed"`
+	IssuesChanged int               `json:"issues_changed"`
+}
+
+// reindex rebuilds the slugs, the issue index and the bloom filter from the
+// stored projects. A slug is kept while it still fits the project's name and
+// no other project has it, so existing URLs only change when they were wrong.
+func (h *projectHandlers) reindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, r, "POST")
+		return
+	}
+
+	start := time.Now()
+	result := reindexResult{SlugsChanged: map[string]string{}}
+
+	h.Lock()
+	ids := make([]string, 0, len(h.db))
+	for id := range h.db {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return lessID(ids[i], ids[j]) })
+
+	taken := map[string]bool{}
+	var stale []string
+	for _, id := range ids {
+		slug := h.db[id].Slug
+		if slugFits(slug, h.db[id].Name) && !taken[slug] {
+			taken[slug] = true
+		} else {
+			stale = append(stale, id)
+		}
+	}
+	for _, id := range stale {
+		project := h.db[id]
+		base := slugify(project.Name)
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[slug] = true
+
+		result.SlugsChanged[id] = slug
+		project.Slug = slug
+		h.db[id] = project
+	}
+
+	previous := h.issueOwners
+	h.rebuildIssueIndex()
+	if h.cfg.UniqueIssues {
+		for issue, owner := range h.issueOwners {
+			if previous[issue] != owner {
+				result.IssuesChanged++
+			}
+		}
+		for issue := range previous {
+			if _, ok := h.issueOwners[issue]; !ok {
+				result.IssuesChanged++
+			}
+		}
+	}
+
+	h.rebuildBloom()
+	if len(result.SlugsChanged) > 0 {
+		h.markDirty()
+	}
+	result.Projects = len(h.db)
+	h.Unlock()
+
+	result.Duration = time.Since(start).String()
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// slugFits reports whether slug is what uniqueSlug could have given name:
+// its slug, possibly with a numeric suffix.
+func slugFits(slug, name string) bool {
+	base := slugify(name)
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok || suffix == "" || suffix[0] == '0' {
+		return false
+	}
+	return strings.Trim(suffix, "0123456789") == ""
+}
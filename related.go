@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const maxRelatedProjects = 20
+
+type relatedProject struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Href         string   `json:"href"`
+	SharedIssues []string `json:"shared_issues"`
+	SharedPRs    []string `json:"shared_prs"`
+}
+
+type projectWithRelated struct {
+	OpenSourceProject
+	Related []relatedProject `json:"related"`
+}
+
+// relatedTo returns up to maxRelatedProjects other projects listing at least
+// one of project's open issues or PRs, ordered by id. It must be called with h
+// locked.
+func (h *projectHandlers) relatedTo(project OpenSourceProject) []relatedProject {
+	var candidates []OpenSourceProject
+	for id, other := range h.db {
+		if id != project.ID {
+			candidates = append(candidates, other)
+		}
+	}
+	sortedByID(candidates)
+
+	related := []relatedProject{}
+	for _, other := range candidates {
+		issues := sharedIDs(project.OpenIssues, other.OpenIssues)
+		prs := sharedIDs(project.OpenPRs, other.OpenPRs)
+		if len(issues) == 0 && len(prs) == 0 {
+			continue
+		}
+
+		related = append(related, relatedProject{
+			ID:           other.ID,
+			Name:         other.Name,
+			Href:         h.location(other.ID),
+			SharedIssues: issues,
+			SharedPRs:    prs,
+		})
+		if len(related) == maxRelatedProjects {
+			break
+		}
+	}
+	return related
+}
+
+func sharedIDs(a, b []string) []string {
+	shared := []string{}
+	for _, id := range a {
+		if slices.Contains(b, id) && !slices.Contains(shared, id) {
+			shared = append(shared, id)
+		}
+	}
+	return shared
+}
+
+// writeProjectWithRelated answers ?expand=related. The related list changes
+// with other projects, so the response carries no validators of its own.
+func (h *projectHandlers) writeProjectWithRelated(w http.ResponseWriter, r *http.Request, project OpenSourceProject, related []relatedProject) {
+	if r.Header.Get("Authorization") != "" {
+		w.Header().Set("Cache-Control", "private, no-store")
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	writeJSON(w, r, http.StatusOK, projectWithRelated{OpenSourceProject: project, Related: related})
+}
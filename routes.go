@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type routeHandler func(w http.ResponseWriter, r *http.Request, param string)
+
+// route maps the methods a path supports to their handlers. In paths, a
+// segment in braces such as "{id}" matches any single segment, which is
+// passed to the handler as param.
+type route struct {
+	path    string
+	methods map[string]routeHandler
+}
+
+// allow lists the route's methods for the Allow header. HEAD is implied by
+// GET and OPTIONS is always answered.
+func (rt route) allow() string {
+	methods := make([]string, 0, len(rt.methods)+2)
+	for method := range rt.methods {
+		methods = append(methods, method)
+	}
+	if _, ok := rt.methods["GET"]; ok && !slices.Contains(methods, "HEAD") {
+		methods = append(methods, "HEAD")
+	}
+	sort.Strings(methods)
+	return strings.Join(append(methods, "OPTIONS"), ", ")
+}
+
+func (rt route) match(path string) (string, bool) {
+	want := strings.Split(rt.path, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return "", false
+	}
+
+	param := ""
+	for i := range want {
+		switch {
+		case strings.HasPrefix(want[i], "{"):
+			param = got[i]
+		case want[i] != got[i]:
+			return "", false
+		}
+	}
+	return param, true
+}
+
+func withoutParam(f http.HandlerFunc) routeHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ string) {
+		f(w, r)
+	}
+}
+
+// routes is the table of everything served below the collection prefix. Paths
+// with literal segments are listed before the "{id}" ones they would shadow.
+func (h *projectHandlers) routes() []route {
+	return []route{
+		{"", map[string]routeHandler{
+			"GET":   withoutParam(h.getAll),
+			"HEAD":  withoutParam(h.head),
+			"POST":  withoutParam(h.post),
+			"PATCH": withoutParam(h.patchMany),
+		}},
+		{"recent", map[string]routeHandler{"GET": withoutParam(h.getRecent)}},
+		{"count", map[string]routeHandler{"GET": withoutParam(h.count)}},
+		{"schema", map[string]routeHandler{"GET": withoutParam(h.schema)}},
+		{"example", map[string]routeHandler{"GET": withoutParam(h.example)}},
+		{"by-slug/{slug}", map[string]routeHandler{"GET": h.getBySlug}},
+		{"by-name/{name}", map[string]routeHandler{"GET": h.getByName}},
+		{"{id}", map[string]routeHandler{"GET": h.getProject, "PUT": h.put}},
+		{"{id}/exists", map[string]routeHandler{"GET": h.exists}},
+		{"{id}/stats", map[string]routeHandler{"GET": h.stats}},
+		{"{id}/clone", map[string]routeHandler{"POST": h.clone}},
+		{"{id}/events", map[string]routeHandler{"GET": h.streamEvents}},
+		{"{id}/issues", map[string]routeHandler{"GET": func(w http.ResponseWriter, r *http.Request, id string) {
+			h.pageIDs(w, r, id, func(p OpenSourceProject) []string { return p.OpenIssues })
+		}}},
+		{"{id}/prs", map[string]routeHandler{"GET": func(w http.ResponseWriter, r *http.Request, id string) {
+			h.pageIDs(w, r, id, func(p OpenSourceProject) []string { return p.OpenPRs })
+		}}},
+	}
+}
+
+// ServeHTTP dispatches a request whose path has had the collection prefix
+// stripped, answering OPTIONS and unsupported methods from the route table.
+func (h *projectHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if r.URL.Path == "" {
+		path = ""
+	}
+
+	for _, rt := range h.routeTable {
+		param, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+
+		setRoute(w, strings.TrimSuffix("/opensource/projects/"+rt.path, "/"))
+
+		if r.Method == "OPTIONS" {
+			writeOptions(w, r, rt.allow())
+			return
+		}
+
+		handler, ok := rt.methods[r.Method]
+		if !ok && r.Method == "HEAD" {
+			handler, ok = rt.methods["GET"]
+		}
+		if !ok {
+			methodNotAllowed(w, r, rt.allow())
+			return
+		}
+
+		handler(w, r, param)
+		return
+	}
+
+	writeError(w, http.StatusBadRequest, "no such project resource")
+}
+
+// methodNotAllowed answers 405 with the methods the resource does support.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed; use %s", r.Method, allow))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionsAllow(t *testing.T) {
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/opensource/projects", "GET, HEAD, PATCH, POST, OPTIONS"},
+		{"/projects", "GET, HEAD, PATCH, POST, OPTIONS"},
+		{"/opensource/projects/count", "GET, HEAD, OPTIONS"},
+		{"/opensource/projects/by-slug/project-1", "GET, HEAD, OPTIONS"},
+		{"/opensource/projects/1", "GET, HEAD, PUT, OPTIONS"},
+		{"/opensource/projects/does-not-exist", "GET, HEAD, PUT, OPTIONS"},
+		{"/opensource/projects/1/clone", "POST, OPTIONS"},
+		{"/opensource/projects/1/issues", "GET, HEAD, OPTIONS"},
+	}
+
+	_, mux := newTestHandlers(t)
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serve(mux, httptest.NewRequest("OPTIONS", tt.path, nil))
+
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("got status %d, want 204", rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("got Allow %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestDeleteCollectionNotAllowed(t *testing.T) {
+	_, mux := newTestHandlers(t)
+
+	rec := serve(mux, httptest.NewRequest("DELETE", "/opensource/projects", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d, want 405", rec.Code)
+	}
+	if got, want := rec.Header().Get("Allow"), "GET, HEAD, PATCH, POST, OPTIONS"; got != want {
+		t.Errorf("got Allow %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	if got, want := rec.Body.String(), `{"error":"method DELETE is not allowed; use GET, HEAD, PATCH, POST, OPTIONS"}`; got != want {
+		t.Errorf("got body %s, want %s", got, want)
+	}
+}
+
+func TestAdminReadsAllowHead(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	tests := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/admin/projects/1/raw", h.rawProject},
+		{"/admin/projects?by=admin", h.projectsByUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serve(tt.handler, httptest.NewRequest("POST", tt.path, nil))
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("got status %d, want 405", rec.Code)
+			}
+			if got, want := rec.Header().Get("Allow"), "GET, HEAD"; got != want {
+				t.Errorf("got Allow %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const createProjectSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "CreateOpenSourceProjectReq",
+  "type": "object",
+  "properties": {
+    "name": {
+      "type": "string"
+    },
+    "open_issues": {
+      "type": ["array", "null"],
+      "items": {"type": ["string", "number"]}
+    },
+    "open_prs": {
+      "type": ["array", "null"],
+      "items": {"type": ["string", "number"]}
+    }
+  }
+}`
+
+// jsonSchema is the subset of draft-07 needed to describe request bodies:
+// type, properties, required and items.
+type jsonSchema struct {
+	Type       schemaTypes            `json:"type"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *jsonSchema            `json:"items"`
+}
+
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+var createProjectReqSchema = mustParseSchema(createProjectSchema)
+
+// exampleCreateProjectReq is served as a body to copy. It is checked against
+// the schema at startup so it can't drift from what post accepts.
+var exampleCreateProjectReq = mustMatchSchema(createProjectReqSchema, CreateOpenSourceProjectReq{
+	Name:       "My open source project",
+	OpenIssues: idList{"101", "102"},
+	OpenPRs:    idList{"201"},
+})
+
+func mustMatchSchema(s *jsonSchema, v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = s.validateBytes(data)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("example doesn't match its schema: %v", err))
+	}
+	return data
+}
+
+func mustParseSchema(raw string) *jsonSchema {
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+	return &s
+}
+
+func (s *jsonSchema) validateBytes(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.validate(v, "$")
+}
+
+func (s *jsonSchema) validate(v any, path string) error {
+	if len(s.Type) > 0 && !slices.Contains(s.Type, jsonType(v)) {
+		if !(jsonType(v) == "number" && isInteger(v) && slices.Contains(s.Type, "integer")) {
+			return fmt.Errorf("%s: expected %s, but got %s", path, strings.Join(s.Type, " or "), jsonType(v))
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if value, ok := v[name]; ok {
+				if err := s.Properties[name].validate(value, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func isInteger(v any) bool {
+	f, ok := v.(float64)
+	return ok && f == float64(int64(f))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type selfTestStep struct {
+	name   string
+	method string
+	path   string
+	body   string
+	status int
+	check  func(body []byte) error
+}
+
+// runSelfTest drives the project handlers through a create/read/update round
+// trip against a fresh in-memory store, printing one line per step. It
+// reports whether every step passed. Nothing is served or written to disk.
+func runSelfTest(cfg config) bool {
+	cfg.Storage = "memory"
+	cfg.DataFile = ""
+
+	admin := &adminPortal{cfg: cfg, basePath: cfg.BasePath, users: map[string]*adminUser{}}
+	h := newProjectHandlers(admin, cfg)
+	mux := http.NewServeMux()
+	h.register(mux)
+
+	var created OpenSourceProject
+	steps := []selfTestStep{
+		{name: "create", method: "POST", path: "/opensource/projects", body: `{"name": "Self-test project", "open_issues": ["9001"]}`, status: http.StatusCreated,
+			check: func(body []byte) error { return json.Unmarshal(body, &created) }},
+		{name: "get", method: "GET", status: http.StatusOK,
+			check: func(body []byte) error { return expectProjectName(body, "Self-test project") }},
+		{name: "patch", method: "PATCH", path: "/opensource/projects", status: http.StatusOK,
+			check: expectPatched},
+		{name: "get after patch", method: "GET", status: http.StatusOK,
+			check: func(body []byte) error { return expectProjectName(body, "Self-test project renamed") }},
+		{name: "reject invalid create", method: "POST", path: "/opensource/projects", body: `{"name": " "}`, status: http.StatusUnprocessableEntity},
+		{name: "get missing", method: "GET", path: "/opensource/projects/does-not-exist", status: http.StatusNotFound},
+	}
+
+	failed := 0
+	for _, step := range steps {
+		// Steps after create address the project it made.
+		if step.path == "" {
+			step.path = "/opensource/projects/" + created.ID
+		}
+		if step.name == "patch" {
+			step.body = fmt.Sprintf(`[{"id": %q, "name": "Self-test project renamed"}]`, created.ID)
+		}
+
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if step.body != "" {
+			req.Header.Set("content-type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		var err error
+		if rec.Code != step.status {
+			err = fmt.Errorf("got status %d, want %d: %s", rec.Code, step.status, strings.TrimSpace(rec.Body.String()))
+		} else if step.check != nil {
+			err = step.check(rec.Body.Bytes())
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("selftest: FAIL %s %s (%s): %v\n", step.method, step.path, step.name, err)
+		} else {
+			fmt.Printf("selftest: ok   %s %s (%s)\n", step.method, step.path, step.name)
+		}
+	}
+
+	fmt.Printf("selftest: %d passed, %d failed\n", len(steps)-failed, failed)
+	return failed == 0
+}
+
+func expectProjectName(body []byte, name string) error {
+	var project OpenSourceProject
+	if err := json.Unmarshal(body, &project); err != nil {
+		return err
+	}
+	if project.Name != name {
+		return fmt.Errorf("got name %q, want %q", project.Name, name)
+	}
+	return nil
+}
+
+func expectPatched(body []byte) error {
+	var results []patchResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if !result.OK {
+			return fmt.Errorf("project %s wasn't patched: %s%v", result.ID, result.Error, result.Errors)
+		}
+	}
+	return nil
+}
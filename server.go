@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// connTracker counts connections that are still open so a forced shutdown can
+// report how many were dropped.
+type connTracker struct {
+	sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: map[net.Conn]struct{}{}}
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.Lock()
+	defer t.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.conns[c] = struct{}{}
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	}
+}
+
+func (t *connTracker) open() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.conns)
+}
+
+// shutdownHookGrace is the least time each shutdown hook gets, even when
+// draining used up the whole shutdown timeout, so that a slow drain can't
+// cost the final flush.
+const shutdownHookGrace = 5 * time.Second
+
+// shutdownHook is a step that runs after the HTTP server has drained, such as
+// saving the store.
+type shutdownHook struct {
+	name string
+	run  func() error
+}
+
+// shutdown drains srv, waiting for in-flight requests before force-closing
+// whatever connections remain, and then runs hooks in order. timeout covers
+// all of it; a hook still running when its time is up is abandoned.
+func shutdown(srv *http.Server, conns *connTracker, timeout time.Duration, hooks ...shutdownHook) {
+	deadline := time.Now().Add(timeout)
+	log.Printf("shutting down: draining HTTP server, waiting up to %s for in-flight requests", timeout)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		dropped := conns.open()
+		srv.Close()
+		log.Printf("shutting down: drain timed out after %s, dropped %d connections", timeout, dropped)
+	} else {
+		log.Printf("shutting down: HTTP server drained")
+	}
+
+	for _, hook := range hooks {
+		wait := max(time.Until(deadline), shutdownHookGrace)
+		log.Printf("shutting down: %s", hook.name)
+
+		done := make(chan error, 1)
+		go func() { done <- hook.run() }()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("shutting down: %s failed: %v", hook.name, err)
+			}
+		case <-time.After(wait):
+			log.Printf("shutting down: gave up on %s after %s", hook.name, wait)
+		}
+	}
+
+	log.Printf("shutdown complete")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestShutdownFlushesDataFile shuts the server down while a create is still
+// being received, and expects the data file to hold that create along with
+// the ones before it once shutdown returns.
+func TestShutdownFlushesDataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	t.Setenv("DATA_FILE", path)
+	h, mux := newTestHandlers(t)
+
+	conns := newConnTracker()
+	srv := &http.Server{Handler: mux, ConnState: conns.track}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	url := "http://" + ln.Addr().String() + "/opensource/projects"
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"name": "Before shutdown"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create before shutdown: got status %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	body := `{"name": "In flight"}`
+	fmt.Fprintf(conn, "POST /opensource/projects HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body[:5])
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(srv, conns, 5*time.Second, h.flushOnShutdown())
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	fmt.Fprint(conn, body[5:])
+	resp, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create during shutdown: got status %d", resp.StatusCode)
+	}
+
+	<-done
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Serve returned %v, want http.ErrServerClosed", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []OpenSourceProject
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, p := range saved {
+		names[p.Name] = true
+	}
+	if len(saved) != 5 || !names["Before shutdown"] || !names["In flight"] {
+		t.Errorf("data file holds %d projects named %v, want the 3 seeded ones and both creates", len(saved), names)
+	}
+}
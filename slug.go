@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// slugify lowercases name, turns runs of spaces and hyphens into a single
+// hyphen and drops everything that isn't a letter or digit.
+func slugify(name string) string {
+	var b strings.Builder
+	pendingHyphen := false
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '_':
+			pendingHyphen = true
+		}
+	}
+
+	if b.Len() == 0 {
+		return "project"
+	}
+	return b.String()
+}
+
+// uniqueSlug returns the slug for name, adding a numeric suffix if another
+// project already uses it. It must be called with h locked.
+func (h *projectHandlers) uniqueSlug(name, id string) string {
+	base := slugify(name)
+
+	taken := map[string]bool{}
+	for _, project := range h.db {
+		if project.ID != id {
+			taken[project.Slug] = true
+		}
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug
+}
+
+func (h *projectHandlers) getBySlug(w http.ResponseWriter, r *http.Request, slug string) {
+	h.RLock()
+	var project OpenSourceProject
+	ok := false
+	for _, p := range h.db {
+		if p.Slug == slug {
+			project, ok = p.clone(), true
+			break
+		}
+	}
+	h.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no project has the slug %s", slug))
+		return
+	}
+
+	h.writeProject(w, r, project)
+}
+
+// findByName returns the projects whose name matches name, ignoring case,
+// ordered by id. It must be called with h locked.
+func (h *projectHandlers) findByName(name string) []OpenSourceProject {
+	var matches []OpenSourceProject
+	for _, p := range h.db {
+		if strings.EqualFold(strings.TrimSpace(p.Name), strings.TrimSpace(name)) {
+			matches = append(matches, p.clone())
+		}
+	}
+	return sortedByID(matches)
+}
+
+// getByName looks a project up by name. Names aren't unique, so several
+// matches are reported as a conflict listing their ids.
+func (h *projectHandlers) getByName(w http.ResponseWriter, r *http.Request, name string) {
+	h.RLock()
+	matches := h.findByName(name)
+	h.RUnlock()
+
+	switch len(matches) {
+	case 0:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no project is named %s", name))
+	case 1:
+		h.writeProject(w, r, matches[0])
+	default:
+		ids := make([]string, len(matches))
+		for i, p := range matches {
+			ids[i] = p.ID
+		}
+		writeJSON(w, r, http.StatusConflict, map[string]any{
+			"error": fmt.Sprintf("%d projects are named %q", len(matches), name),
+			"ids":   ids,
+		})
+	}
+}
+
+// nameOwner returns the id of another project already using name, ignoring
+// case, when -unique-names is set. It must be called with h locked.
+func (h *projectHandlers) nameOwner(id, name string) (string, bool) {
+	if !h.cfg.UniqueNames {
+		return "", false
+	}
+
+	for _, p := range h.findByName(name) {
+		if p.ID != id {
+			return p.ID, true
+		}
+	}
+	return "", false
+}
+
+func describeNameConflict(name, owner string) string {
+	return fmt.Sprintf("name %q is already used by project %s", strings.TrimSpace(name), owner)
+}
+
+func writeNameConflict(w http.ResponseWriter, r *http.Request, name, owner string) {
+	writeJSON(w, r, http.StatusConflict, map[string]any{
+		"error": describeNameConflict(name, owner),
+		"id":    owner,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var sortFields = []string{"id", "name", "created_at", "updated_at"}
+
+// sortOrder is a listing order written as "field" or "field:asc|desc". Ties
+// are broken by id so the order is always deterministic.
+type sortOrder struct {
+	field string
+	desc  bool
+}
+
+func parseSortOrder(s string) (sortOrder, error) {
+	field, dir, _ := strings.Cut(strings.TrimSpace(s), ":")
+
+	var order sortOrder
+	switch dir {
+	case "", "asc":
+	case "desc":
+		order.desc = true
+	default:
+		return order, fmt.Errorf("invalid sort %q: direction must be asc or desc", s)
+	}
+
+	if !slices.Contains(sortFields, field) {
+		return order, fmt.Errorf("invalid sort %q: field must be one of %s", s, strings.Join(sortFields, ", "))
+	}
+	order.field = field
+	return order, nil
+}
+
+func (o sortOrder) String() string {
+	if o.desc {
+		return o.field + ":desc"
+	}
+	return o.field + ":asc"
+}
+
+func (o sortOrder) apply(projects []OpenSourceProject) {
+	sort.SliceStable(projects, func(i, j int) bool {
+		a, b := projects[i], projects[j]
+		if o.desc {
+			a, b = b, a
+		}
+
+		switch o.field {
+		case "name":
+			if x, y := strings.ToLower(a.Name), strings.ToLower(b.Name); x != y {
+				return x < y
+			}
+		case "created_at":
+			if !a.CreatedAt.Equal(b.CreatedAt.Time) {
+				return a.CreatedAt.Before(b.CreatedAt.Time)
+			}
+		case "updated_at":
+			if !a.UpdatedAt.Equal(b.UpdatedAt.Time) {
+				return a.UpdatedAt.Before(b.UpdatedAt.Time)
+			}
+		}
+		return lessID(a.ID, b.ID)
+	})
+}
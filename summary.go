@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+type projectSummary struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Issues int    `json:"issues"`
+	PRs    int    `json:"prs"`
+}
+
+type summaryTotals struct {
+	Projects int `json:"projects"`
+	Issues   int `json:"issues"`
+	PRs      int `json:"prs"`
+}
+
+type summary struct {
+	Projects []projectSummary `json:"projects"`
+	Totals   summaryTotals    `json:"totals"`
+}
+
+// summary counts every project's open issues and PRs in one pass, busiest
+// first. ?limit=N keeps only the top N projects; the totals always cover all
+// of them.
+func (h *projectHandlers) summary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be a positive integer, but got %s", s))
+			return
+		}
+		limit = n
+	}
+
+	var result summary
+
+	h.RLock()
+	result.Projects = make([]projectSummary, 0, len(h.db))
+	for _, p := range h.db {
+		result.Projects = append(result.Projects, projectSummary{ID: p.ID, Name: p.Name, Issues: len(p.OpenIssues), PRs: len(p.OpenPRs)})
+		result.Totals.Issues += len(p.OpenIssues)
+		result.Totals.PRs += len(p.OpenPRs)
+	}
+	h.RUnlock()
+
+	result.Totals.Projects = len(result.Projects)
+	sort.Slice(result.Projects, func(i, j int) bool {
+		a, b := result.Projects[i], result.Projects[j]
+		if a.Issues+a.PRs != b.Issues+b.PRs {
+			return a.Issues+a.PRs > b.Issues+b.PRs
+		}
+		return lessID(a.ID, b.ID)
+	})
+	if limit > 0 && len(result.Projects) > limit {
+		result.Projects = result.Projects[:limit]
+	}
+
+	h.cacheCollection(w, r)
+	writeJSON(w, r, http.StatusOK, result)
+}
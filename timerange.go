@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// timeRange is an interval of timestamps read from the query. A zero bound is
+// open.
+type timeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (tr timeRange) contains(t time.Time) bool {
+	return (tr.From.IsZero() || t.After(tr.From)) && (tr.To.IsZero() || !t.After(tr.To))
+}
+
+// parseTimeRange reads the from, to and since query parameters. from and to
+// take an RFC 3339 timestamp or a positive duration meaning that long ago;
+// since is shorthand for from. defaultSince applies when neither from nor
+// since is given, and 0 leaves the range open.
+func parseTimeRange(r *http.Request, defaultSince time.Duration) (timeRange, error) {
+	query := r.URL.Query()
+	now := time.Now()
+
+	var tr timeRange
+	var err error
+	if tr.From, err = parseTimeParam("from", query.Get("from"), now); err != nil {
+		return tr, err
+	}
+	if tr.To, err = parseTimeParam("to", query.Get("to"), now); err != nil {
+		return tr, err
+	}
+
+	if since := query.Get("since"); since != "" {
+		if !tr.From.IsZero() {
+			return tr, fmt.Errorf("since and from can't be used together")
+		}
+		if tr.From, err = parseTimeParam("since", since, now); err != nil {
+			return tr, err
+		}
+	}
+
+	if tr.From.IsZero() && defaultSince > 0 {
+		tr.From = now.Add(-defaultSince)
+	}
+
+	if !tr.From.IsZero() && !tr.To.IsZero() && tr.To.Before(tr.From) {
+		return tr, fmt.Errorf("to must not be before from")
+	}
+
+	return tr, nil
+}
+
+func parseTimeParam(name, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time such as 2024-01-02T15:04:05Z or a positive duration such as 24h, but got %s", name, value)
+}
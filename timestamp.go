@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var timeFormats = []string{"rfc3339", "rfc3339nano", "unix"}
+
+// timeFormat is how project timestamps are written, set from -time-format at
+// startup.
+var timeFormat = "rfc3339"
+
+// timestamp is a project time that marshals in the -time-format format in API
+// output. Unmarshaling accepts every format. The data file doesn't use it and
+// always keeps full precision; see storedProject.
+type timestamp struct {
+	time.Time
+}
+
+func (t timestamp) MarshalJSON() ([]byte, error) {
+	switch timeFormat {
+	case "unix":
+		return strconv.AppendInt(nil, t.Unix(), 10), nil
+	case "rfc3339nano":
+		return json.Marshal(t.Format(time.RFC3339Nano))
+	default:
+		return json.Marshal(t.Format(time.RFC3339))
+	}
+}
+
+func (t *timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+
+	seconds, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp must be an RFC 3339 string or unix seconds, but got %s", data)
+	}
+	t.Time = time.Unix(seconds, 0).UTC()
+	return nil
+}
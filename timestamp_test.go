@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setTimeFormat(t *testing.T, format string) {
+	previous := timeFormat
+	timeFormat = format
+	t.Cleanup(func() { timeFormat = previous })
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	tests := []struct {
+		format string
+		json   string
+		want   time.Time
+	}{
+		{format: "rfc3339", json: `"2024-05-01T12:00:00Z"`, want: at.Truncate(time.Second)},
+		{format: "rfc3339nano", json: `"2024-05-01T12:00:00.123456789Z"`, want: at},
+		{format: "unix", json: `1714564800`, want: at.Truncate(time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			setTimeFormat(t, tt.format)
+
+			data, err := json.Marshal(timestamp{at})
+			if err != nil || string(data) != tt.json {
+				t.Fatalf("got %s (%v), want %s", data, err, tt.json)
+			}
+			var got timestamp
+			if err := json.Unmarshal(data, &got); err != nil || !got.Equal(tt.want) {
+				t.Errorf("read back %s (%v), want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDataFileKeepsNanoseconds checks that -time-format only shapes API
+// output: whatever it is, a flush and reload keep timestamps exact.
+func TestDataFileKeepsNanoseconds(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+
+	for _, format := range timeFormats {
+		t.Run(format, func(t *testing.T) {
+			setTimeFormat(t, format)
+			path := filepath.Join(t.TempDir(), "projects.json")
+			t.Setenv("DATA_FILE", path)
+
+			h, _ := newTestHandlers(t)
+			h.Lock()
+			project := h.db["1"]
+			project.CreatedAt, project.UpdatedAt = timestamp{at}, timestamp{at.Add(time.Nanosecond)}
+			h.db["1"] = project
+			h.Unlock()
+			if _, err := h.flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded, _ := newTestHandlers(t)
+			if _, err := reloaded.loadFile(path); err != nil {
+				t.Fatal(err)
+			}
+			got := reloaded.db["1"]
+			if !got.CreatedAt.Equal(at) || !got.UpdatedAt.Equal(at.Add(time.Nanosecond)) {
+				t.Errorf("got created %s and updated %s back, want %s and %s", got.CreatedAt, got.UpdatedAt, at, at.Add(time.Nanosecond))
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	roleRead  = "read"
+	roleWrite = "write"
+)
+
+type adminUser struct {
+	role       string
+	credential atomic.Pointer[credential]
+}
+
+// minUserIterations keeps ADMIN_USERS hashes from being cheap enough to
+// brute-force; the server itself uses pbkdf2Iterations.
+const minUserIterations = 100_000
+
+// parseAdminUsers reads ADMIN_USERS, a comma-separated list of
+// user:role:iterations:salt:hash entries where hash is the hex
+// PBKDF2-HMAC-SHA256 of the password with that salt and iteration count, the
+// same scheme the admin password is stored with.
+func parseAdminUsers(spec string) (map[string]*adminUser, error) {
+	users := map[string]*adminUser{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 5)
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry %q: expected user:role:iterations:salt:hash", entry)
+		}
+		name, role, salt, hexHash := parts[0], parts[1], parts[3], parts[4]
+
+		switch {
+		case name == "" || salt == "":
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry for %q: user and salt must not be empty", name)
+		case name == "admin":
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry: the admin user is configured through ADMIN_PASSWORD")
+		case role != roleRead && role != roleWrite:
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry for %q: role must be %s or %s, but got %q", name, roleRead, roleWrite, role)
+		case users[name] != nil:
+			return nil, fmt.Errorf("invalid ADMIN_USERS: user %q is listed twice", name)
+		}
+
+		iterations, err := strconv.Atoi(parts[2])
+		if err != nil || iterations < minUserIterations {
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry for %q: iterations must be a number of at least %d", name, minUserIterations)
+		}
+
+		hash, err := hex.DecodeString(hexHash)
+		if err != nil || len(hash) != 32 {
+			return nil, fmt.Errorf("invalid ADMIN_USERS entry for %q: hash must be 64 hex digits", name)
+		}
+
+		user := &adminUser{role: role}
+		user.credential.Store(&credential{Salt: []byte(salt), Hash: hash, Iterations: iterations})
+		users[name] = user
+	}
+	return users, nil
+}
+
+// requiredRole is the role a request needs: read for safe methods and write
+// for anything that changes state.
+func requiredRole(r *http.Request) string {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return roleRead
+	}
+	return roleWrite
+}
+
+// unknownUser is checked against when a request names a user that doesn't
+// exist, so that takes as long as a wrong password.
+var unknownUser = func() *credential {
+	cred, err := newCredential("")
+	if err != nil {
+		panic(err)
+	}
+	return cred
+}()
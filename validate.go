@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const maxNameLength = 200
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type validationErrors []fieldError
+
+func (errs *validationErrors) add(field, format string, args ...any) {
+	*errs = append(*errs, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the request against the domain rules that the JSON schema
+// can't express. It reports every violation rather than stopping at the first.
+func (req CreateOpenSourceProjectReq) Validate(cfg config) validationErrors {
+	var errs validationErrors
+
+	validateName(&errs, req.Name, cfg)
+	validateIDs(&errs, "open_issues", req.OpenIssues, cfg.MaxListItems)
+	validateIDs(&errs, "open_prs", req.OpenPRs, cfg.MaxListItems)
+
+	if !cfg.AllowIssuePROverlap {
+		var shared []string
+		for _, id := range req.OpenIssues {
+			if slices.Contains(req.OpenPRs, id) && !slices.Contains(shared, id) {
+				shared = append(shared, id)
+			}
+		}
+		if len(shared) > 0 {
+			errs.add("open_prs", "ids can't be both an open issue and an open PR: %s", strings.Join(shared, ", "))
+		}
+	}
+
+	return errs
+}
+
+func validateName(errs *validationErrors, name string, cfg config) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		errs.add("name", "must not be empty")
+	case len(name) > maxNameLength:
+		errs.add("name", "must be at most %d characters, but is %d", maxNameLength, len(name))
+	case !cfg.nameRegexp.MatchString(name):
+		errs.add("name", "must match the pattern %s set by -name-pattern", cfg.NamePattern)
+	}
+}
+
+func validateIDs(errs *validationErrors, field string, ids []string, max int) {
+	if len(ids) > max {
+		errs.add(field, "must have at most %d ids, but has %d", max, len(ids))
+	}
+
+	for i, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			errs.add(fmt.Sprintf("%s[%d]", field, i), "must not be empty")
+		}
+	}
+}
+
+// writeValidationErrors reports every problem found in a request body. Bodies
+// that parse but break a domain rule get 422 rather than 400, which is kept
+// for bodies that can't be parsed at all.
+func writeValidationErrors(w http.ResponseWriter, status int, errs validationErrors) {
+	jsonBytes, _ := json.Marshal(map[string]validationErrors{"errors": errs})
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
+
+type invalidProject struct {
+	ID     string           `json:"id"`
+	Errors validationErrors `json:"errors"`
+}
+
+// validateAll rechecks every stored project against the current rules, for
+// data saved before a rule changed or loaded from a file. It changes nothing.
+func (h *projectHandlers) validateAll(w http.ResponseWriter, r *http.Request) {
+	invalid := []invalidProject{}
+
+	h.RLock()
+	checked := len(h.db)
+	for id, p := range h.db {
+		req := CreateOpenSourceProjectReq{Name: p.Name, OpenIssues: p.OpenIssues, OpenPRs: p.OpenPRs}
+		if errs := req.Validate(h.cfg); len(errs) > 0 {
+			invalid = append(invalid, invalidProject{ID: id, Errors: errs})
+		}
+	}
+	h.RUnlock()
+
+	sort.Slice(invalid, func(i, j int) bool { return lessID(invalid[i].ID, invalid[j].ID) })
+
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"checked": checked,
+		"invalid": invalid,
+	})
+}
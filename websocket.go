@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// websocketGUID is the fixed key suffix from RFC 6455 section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpText   = 0x1
+	wsOpBinary = 0x2
+	wsOpClose  = 0x8
+	wsOpPing   = 0x9
+	wsOpPong   = 0xA
+
+	wsCloseNormal       = 1000
+	wsCloseGoingAway    = 1001
+	wsCloseProtocol     = 1002
+	wsCloseTooBig       = 1009
+	wsMaxMessageBytes   = 64 << 10
+	wsMaxControlPayload = 125
+)
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn writes frames to a hijacked connection. Writes come from both the
+// event loop and the reader answering pings, so they are serialized.
+type wsConn struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	rw       *bufio.ReadWriter
+	lastPong atomic.Int64
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeClose(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	return c.writeFrame(wsOpClose, append(payload, reason...))
+}
+
+// readFrame reads one client frame, which must be masked. Clients have
+// nothing to send but control frames, so data frames are read and dropped
+// without reassembling fragmented messages.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.rw, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return fin, opcode, nil, errors.New("client frames must be masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > wsMaxControlPayload || !fin) {
+		return fin, opcode, nil, errors.New("invalid control frame")
+	}
+	if length > wsMaxMessageBytes {
+		return fin, opcode, nil, errTooBig
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.rw, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+var errTooBig = errors.New("frame too big")
+
+// readLoop answers pings and records pongs until the client closes the
+// connection or breaks the protocol. It reports the close code to send, if
+// any, on done.
+func (c *wsConn) readLoop(done chan<- uint16) {
+	for {
+		_, opcode, payload, err := c.readFrame()
+		switch {
+		case errors.Is(err, errTooBig):
+			done <- wsCloseTooBig
+			return
+		case err != nil && (errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrUnexpectedEOF)):
+			done <- 0
+			return
+		case err != nil:
+			done <- wsCloseProtocol
+			return
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if c.writeFrame(wsOpPong, payload) != nil {
+				done <- 0
+				return
+			}
+		case wsOpPong:
+			c.lastPong.Store(time.Now().UnixNano())
+		case wsOpClose:
+			done <- wsCloseNormal
+			return
+		case 0x0, wsOpText, wsOpBinary:
+		default:
+			done <- wsCloseProtocol
+			return
+		}
+	}
+}
+
+// streamWebSocket pushes the change feed of every project over a WebSocket,
+// as an alternative to /opensource/events. Each event is sent as a text
+// message holding {"type": ..., "project": ...}; ?type= filters like the SSE
+// feed does.
+func (h *projectHandlers) streamWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, r, "GET")
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") || key == "" {
+		writeError(w, http.StatusBadRequest, "expected a WebSocket upgrade request")
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "unsupported WebSocket version")
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	ws := &wsConn{conn: conn, rw: rw}
+	ws.lastPong.Store(time.Now().UnixNano())
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if rw.Flush() != nil {
+		return
+	}
+	releaseInFlightSlot(r)
+
+	events, unsubscribe := h.events.subscribe("")
+	defer unsubscribe()
+
+	done := make(chan uint16, 1)
+	go ws.readLoop(done)
+
+	eventType := r.URL.Query().Get("type")
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case code := <-done:
+			if code != 0 {
+				ws.writeClose(code, "")
+			}
+			return
+		case <-keepAlive.C:
+			if time.Since(time.Unix(0, ws.lastPong.Load())) > 2*keepAliveInterval+keepAliveInterval/2 {
+				ws.writeClose(wsCloseGoingAway, "ping timeout")
+				return
+			}
+			if ws.writeFrame(wsOpPing, nil) != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				ws.writeClose(wsCloseGoingAway, "server shutting down")
+				return
+			}
+			if eventType != "" && event.Type != eventType {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if ws.writeFrame(wsOpText, data) != nil {
+				return
+			}
+		}
+	}
+}